@@ -0,0 +1,251 @@
+package aichat
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheEntry 缓存的完整响应
+type CacheEntry struct {
+	Content      string    `json:"content"`
+	FinishReason string    `json:"finish_reason,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// CacheStore 响应缓存存储接口
+type CacheStore interface {
+	// Get 读取缓存，未命中或已过期时返回 false
+	Get(ctx context.Context, key string) (*CacheEntry, bool, error)
+	// Set 写入缓存，ttl 为 0 表示不过期
+	Set(ctx context.Context, key string, entry *CacheEntry, ttl time.Duration) error
+}
+
+// CacheOptions 响应缓存配置
+type CacheOptions struct {
+	TTL          time.Duration // 缓存有效期，为 0 表示不过期
+	MaxEntrySize int           // 单条响应超过该字节数时不缓存，为 0 表示不限制
+	ReplayChunk  int           // 回放时每个块的字符数，为 0 时使用默认值
+	ReplayDelay  time.Duration // 回放时块之间的间隔，用于模拟流式输出
+}
+
+const defaultReplayChunk = 16
+
+// CachingProvider 精确匹配的响应缓存，命中时以流的形式回放缓存的响应
+//
+// 只缓存 temperature 为 0 的请求，其余请求直接透传给上游。
+type CachingProvider struct {
+	provider ModelProvider
+	store    CacheStore
+	options  CacheOptions
+}
+
+func NewCachingProvider(provider ModelProvider, store CacheStore, options CacheOptions) *CachingProvider {
+	if options.ReplayChunk <= 0 {
+		options.ReplayChunk = defaultReplayChunk
+	}
+	return &CachingProvider{
+		provider: provider,
+		store:    store,
+		options:  options,
+	}
+}
+
+func (p *CachingProvider) IsAvailable() bool {
+	return p.provider.IsAvailable()
+}
+
+func (p *CachingProvider) StreamChat(ctx context.Context, req *ChatRequest) (<-chan StreamChunk, error) {
+	if req.Temperature != 0 {
+		return p.provider.StreamChat(ctx, req)
+	}
+
+	key := CacheKey(req)
+	if entry, ok, err := p.store.Get(ctx, key); err == nil && ok {
+		return replayEntry(ctx, entry, p.options.ReplayChunk, p.options.ReplayDelay), nil
+	}
+
+	upstream, err := p.provider.StreamChat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return forwardAndCollect(ctx, upstream, func(entry *CacheEntry) {
+		if p.options.MaxEntrySize > 0 && len(entry.Content) > p.options.MaxEntrySize {
+			return
+		}
+		_ = p.store.Set(context.WithoutCancel(ctx), key, entry, p.options.TTL)
+	}), nil
+}
+
+// forwardAndCollect 转发上游的块并收集完整响应，响应完整时在关闭输出前调用 complete。
+// 没有错误、未取消且带有结束原因才算完整，上游无结束原因就关闭视为被截断。
+// 调用方取消 ctx 放弃读取后，仍会消费完上游
+func forwardAndCollect(ctx context.Context, upstream <-chan StreamChunk, complete func(*CacheEntry)) <-chan StreamChunk {
+	chunks := make(chan StreamChunk, 100)
+	go func() {
+		defer close(chunks)
+
+		var content strings.Builder
+		var finishReason string
+		var streamErr error
+		forward := true
+		for chunk := range upstream {
+			if chunk.Error != nil {
+				streamErr = chunk.Error
+			}
+			if chunk.FinishReason != "" {
+				finishReason = chunk.FinishReason
+			}
+			content.WriteString(chunk.Content)
+			if !forward {
+				continue
+			}
+			select {
+			case chunks <- chunk:
+			case <-ctx.Done():
+				forward = false
+			}
+		}
+
+		if streamErr != nil || ctx.Err() != nil || finishReason == "" {
+			return
+		}
+		complete(&CacheEntry{Content: content.String(), FinishReason: finishReason, CreatedAt: time.Now()})
+	}()
+	return chunks
+}
+
+// CacheKey 计算请求的规范化哈希，Stream 字段不参与计算
+func CacheKey(req *ChatRequest) string {
+	normalized := *req
+	normalized.Stream = false
+	data, _ := json.Marshal(normalized)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// replayEntry 将缓存的响应按块回放为流，取消后不再阻塞在发送上
+func replayEntry(ctx context.Context, entry *CacheEntry, chunkSize int, delay time.Duration) <-chan StreamChunk {
+	chunks := make(chan StreamChunk, 100)
+	go func() {
+		defer close(chunks)
+
+		// send 在取消时放弃发送，调用方可能已离开，缓冲区有空间时才告知取消
+		send := func(chunk StreamChunk) bool {
+			select {
+			case chunks <- chunk:
+				return true
+			case <-ctx.Done():
+				select {
+				case chunks <- StreamChunk{Error: ctx.Err()}:
+				default:
+				}
+				return false
+			}
+		}
+
+		runes := []rune(entry.Content)
+		for i := 0; i < len(runes); i += chunkSize {
+			end := min(i+chunkSize, len(runes))
+			if !send(StreamChunk{Content: string(runes[i:end])}) {
+				return
+			}
+			if delay > 0 && end < len(runes) {
+				select {
+				case <-ctx.Done():
+				case <-time.After(delay):
+				}
+			}
+		}
+		if entry.FinishReason != "" {
+			send(StreamChunk{FinishReason: entry.FinishReason})
+		}
+	}()
+	return chunks
+}
+
+// MemoryCacheStore 内存缓存，按最近最少使用淘汰
+type MemoryCacheStore struct {
+	maxEntries int // 最大条数，为 0 表示不限制
+	maxBytes   int // 最大总字节数，为 0 表示不限制
+
+	entries map[string]*list.Element
+	lru     *list.List
+	bytes   int
+	mu      sync.Mutex
+}
+
+type memoryCacheItem struct {
+	key       string
+	entry     *CacheEntry
+	expiresAt time.Time
+}
+
+func NewMemoryCacheStore(maxEntries, maxBytes int) *MemoryCacheStore {
+	return &MemoryCacheStore{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+func (s *MemoryCacheStore) Get(ctx context.Context, key string) (*CacheEntry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	item := elem.Value.(*memoryCacheItem)
+	if !item.expiresAt.IsZero() && time.Now().After(item.expiresAt) {
+		s.remove(elem)
+		return nil, false, nil
+	}
+	s.lru.MoveToFront(elem)
+	return item.entry, true, nil
+}
+
+func (s *MemoryCacheStore) Set(ctx context.Context, key string, entry *CacheEntry, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.entries[key]; ok {
+		s.remove(elem)
+	}
+	if s.maxBytes > 0 && len(entry.Content) > s.maxBytes {
+		return nil
+	}
+
+	item := &memoryCacheItem{key: key, entry: entry}
+	if ttl > 0 {
+		item.expiresAt = time.Now().Add(ttl)
+	}
+	s.entries[key] = s.lru.PushFront(item)
+	s.bytes += len(entry.Content)
+
+	for (s.maxEntries > 0 && s.lru.Len() > s.maxEntries) || (s.maxBytes > 0 && s.bytes > s.maxBytes) {
+		s.remove(s.lru.Back())
+	}
+	return nil
+}
+
+// Len 返回当前缓存条数
+func (s *MemoryCacheStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Len()
+}
+
+func (s *MemoryCacheStore) remove(elem *list.Element) {
+	item := s.lru.Remove(elem).(*memoryCacheItem)
+	delete(s.entries, item.key)
+	s.bytes -= len(item.entry.Content)
+}
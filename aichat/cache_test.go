@@ -0,0 +1,212 @@
+package aichat
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCachingProvider_StreamChat(t *testing.T) {
+	ctx := context.Background()
+	upstream := &summaryProvider{}
+	store := NewMemoryCacheStore(10, 0)
+	provider := NewCachingProvider(upstream, store, CacheOptions{ReplayChunk: 3})
+
+	req := &ChatRequest{Model: "mock", Messages: []ChatMessage{{Role: "user", Content: "hi"}}, Stream: true}
+	collect := func(req *ChatRequest) (string, int) {
+		t.Helper()
+		chunks, err := provider.StreamChat(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		content := ""
+		for chunk := range chunks {
+			if chunk.Error != nil {
+				t.Fatal(chunk.Error)
+			}
+			content += chunk.Content
+			n++
+		}
+		return content, n
+	}
+
+	if content, _ := collect(req); content != "summary 1" {
+		t.Errorf("Expected %q, got %q", "summary 1", content)
+	}
+
+	// 流读完时缓存已写入
+	if store.Len() != 1 {
+		t.Fatalf("Expected 1 cached entry, got %d", store.Len())
+	}
+
+	// 命中缓存：内容相同，上游不再调用，按块回放
+	replay := *req
+	replay.Stream = false
+	content, n := collect(&replay)
+	if content != "summary 1" || upstream.calls != 1 {
+		t.Errorf("Expected cached %q with 1 upstream call, got %q with %d calls", "summary 1", content, upstream.calls)
+	}
+	if n != 4 {
+		t.Errorf("Expected 3 replayed chunks and a finish chunk, got %d", n)
+	}
+
+	// 非零温度绕过缓存
+	bypass := *req
+	bypass.Temperature = 0.7
+	if content, _ = collect(&bypass); content != "summary 2" {
+		t.Errorf("Expected bypass to hit upstream, got %q", content)
+	}
+}
+
+func TestCachingProvider_Incomplete(t *testing.T) {
+	store := NewMemoryCacheStore(10, 0)
+	req := &ChatRequest{Model: "mock", Messages: []ChatMessage{{Role: "user", Content: "hi"}}}
+
+	// 上游没有结束原因就关闭，视为被截断，不缓存
+	provider := NewCachingProvider(&flakyProvider{n: 3}, store, CacheOptions{})
+	chunks, err := provider.StreamChat(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = CollectStream(chunks); err != nil {
+		t.Fatal(err)
+	}
+	if store.Len() != 0 {
+		t.Errorf("Expected truncated response not to be cached, got %d entries", store.Len())
+	}
+
+	// 调用方取消后不再读取，转发 goroutine 仍会结束
+	ctx, cancel := context.WithCancel(context.Background())
+	provider = NewCachingProvider(&flakyProvider{n: 1000}, store, CacheOptions{})
+	if chunks, err = provider.StreamChat(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	<-chunks
+	cancel()
+	timeout := time.After(2 * time.Second)
+	for open := true; open; {
+		select {
+		case _, open = <-chunks:
+		case <-timeout:
+			t.Fatal("Expected stream to close after cancel")
+		}
+	}
+}
+
+func TestReplayEntry_Cancel(t *testing.T) {
+	// 回放内容填满缓冲区后调用方取消且不再读取，回放 goroutine 仍应退出
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	entry := &CacheEntry{Content: strings.Repeat("x", 100), FinishReason: "stop"}
+	chunks := replayEntry(ctx, entry, 1, 0)
+	for len(chunks) < cap(chunks) {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected replay goroutine to exit after cancel")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestMemoryCacheStore(t *testing.T) {
+	ctx := context.Background()
+
+	store := NewMemoryCacheStore(2, 0)
+	_ = store.Set(ctx, "a", &CacheEntry{Content: "a"}, 0)
+	_ = store.Set(ctx, "b", &CacheEntry{Content: "b"}, 0)
+	_, _, _ = store.Get(ctx, "a")
+	_ = store.Set(ctx, "c", &CacheEntry{Content: "c"}, 0)
+	if _, ok, _ := store.Get(ctx, "b"); ok {
+		t.Error("Expected least recently used entry to be evicted")
+	}
+	if _, ok, _ := store.Get(ctx, "a"); !ok {
+		t.Error("Expected recently used entry to be kept")
+	}
+
+	_ = store.Set(ctx, "ttl", &CacheEntry{Content: "ttl"}, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok, _ := store.Get(ctx, "ttl"); ok {
+		t.Error("Expected expired entry to miss")
+	}
+
+	sized := NewMemoryCacheStore(0, 4)
+	_ = sized.Set(ctx, "a", &CacheEntry{Content: "aaa"}, 0)
+	_ = sized.Set(ctx, "b", &CacheEntry{Content: "bb"}, 0)
+	if sized.Len() != 1 {
+		t.Errorf("Expected size limit to evict, got %d entries", sized.Len())
+	}
+}
+
+// keywordEmbedder 按关键词生成向量，包含相同关键词的文本相似度为 1
+type keywordEmbedder struct {
+	keywords []string
+}
+
+func (e keywordEmbedder) Embed(ctx context.Context, input []string) ([][]float32, error) {
+	vectors := make([][]float32, len(input))
+	for i, text := range input {
+		vectors[i] = make([]float32, len(e.keywords))
+		for j, kw := range e.keywords {
+			if strings.Contains(text, kw) {
+				vectors[i][j] = 1
+			}
+		}
+	}
+	return vectors, nil
+}
+
+func TestSemanticCachingProvider_StreamChat(t *testing.T) {
+	ctx := context.Background()
+	upstream := &summaryProvider{}
+	provider := NewSemanticCachingProvider(upstream, keywordEmbedder{keywords: []string{"weather", "time"}}, SemanticCacheOptions{})
+
+	ask := func(model, system, question string) string {
+		t.Helper()
+		req := &ChatRequest{Model: model, Messages: []ChatMessage{
+			{Role: "system", Content: system},
+			{Role: "user", Content: question},
+		}}
+		chunks, err := provider.StreamChat(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := CollectStream(chunks)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return content
+	}
+
+	if got := ask("m1", "sys", "what is the weather today"); got != "summary 1" {
+		t.Errorf("Expected upstream answer, got %q", got)
+	}
+	// 流读完时缓存已写入
+	if provider.Stats().Entries != 1 {
+		t.Fatalf("Expected 1 cached entry, got %+v", provider.Stats())
+	}
+
+	if got := ask("m1", "sys", "weather please"); got != "summary 1" {
+		t.Errorf("Expected similar question to hit cache, got %q", got)
+	}
+	if got := ask("m1", "sys", "what time is it"); got != "summary 2" {
+		t.Errorf("Expected different question to miss, got %q", got)
+	}
+	if got := ask("m2", "sys", "weather please"); got != "summary 3" {
+		t.Errorf("Expected other model to miss, got %q", got)
+	}
+	if got := ask("m1", "other", "weather please"); got != "summary 4" {
+		t.Errorf("Expected other system prompt to miss, got %q", got)
+	}
+
+	if stats := provider.Stats(); stats.Hits != 1 || stats.Misses != 4 {
+		t.Errorf("Expected 1 hit and 4 misses, got %+v", stats)
+	}
+}
@@ -2,12 +2,28 @@ package aichat
 
 import (
 	"context"
+	"strings"
 )
 
 // ChatMessage 聊天消息
 type ChatMessage struct {
-	Role    string `json:"role"` // system, user, assistant
-	Content string `json:"content"`
+	Role       string     `json:"role"` // system, user, assistant, tool
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`   // assistant 发起的工具调用
+	ToolCallID string     `json:"tool_call_id,omitempty"` // tool 消息对应的调用 ID
+}
+
+// ToolCall 工具调用
+type ToolCall struct {
+	ID       string       `json:"id"`
+	Type     string       `json:"type"` // function
+	Function FunctionCall `json:"function"`
+}
+
+// FunctionCall 函数调用参数
+type FunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
 }
 
 // ChatRequest 聊天请求
@@ -52,3 +68,21 @@ type ModelFactory interface {
 	GetProvider(modelName string) (ModelProvider, error)
 	ListAvailableModels() []string
 }
+
+// EmbeddingFactory 向量模型工厂
+type EmbeddingFactory interface {
+	GetEmbeddingProvider(modelName string) (EmbeddingProvider, error)
+	ListAvailableEmbeddingModels() []string
+}
+
+// CollectStream 读取完整的流式响应，返回拼接后的内容
+func CollectStream(chunks <-chan StreamChunk) (string, error) {
+	var sb strings.Builder
+	for chunk := range chunks {
+		if chunk.Error != nil {
+			return sb.String(), chunk.Error
+		}
+		sb.WriteString(chunk.Content)
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,95 @@
+package aichat
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"chatlib/stream"
+)
+
+// CoalesceChunks 合并连续的内容块，按 policy 决定何时输出，减少下游的事件数量。
+// 结束原因和错误块会先输出已合并的内容，再原样转发。policy 为零值时直接返回 chunks；
+// ctx 结束后停止输出，并在后台读完 chunks
+func CoalesceChunks(ctx context.Context, chunks <-chan StreamChunk, policy stream.FlushPolicy) <-chan StreamChunk {
+	if policy.PerEvent() {
+		return chunks
+	}
+
+	out := make(chan StreamChunk)
+	go func() {
+		defer close(out)
+		defer func() { go drainChunks(chunks) }()
+
+		var (
+			pending strings.Builder
+			timer   *time.Timer
+			expired <-chan time.Time
+		)
+		send := func(chunk StreamChunk) bool {
+			select {
+			case <-ctx.Done():
+				return false
+			case out <- chunk:
+				return true
+			}
+		}
+		flush := func() bool {
+			if timer != nil {
+				timer.Stop()
+				expired = nil
+			}
+			if pending.Len() == 0 {
+				return true
+			}
+			content := pending.String()
+			pending.Reset()
+			return send(StreamChunk{Content: content})
+		}
+		defer func() {
+			if timer != nil {
+				timer.Stop()
+			}
+		}()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-expired:
+				expired = nil
+				if !flush() {
+					return
+				}
+			case chunk, ok := <-chunks:
+				if !ok {
+					flush()
+					return
+				}
+				if chunk.Error != nil || chunk.FinishReason != "" {
+					pending.WriteString(chunk.Content)
+					chunk.Content = ""
+					if !flush() || !send(chunk) {
+						return
+					}
+					continue
+				}
+
+				pending.WriteString(chunk.Content)
+				if policy.Due(pending.Len(), []byte(chunk.Content)) {
+					if !flush() {
+						return
+					}
+				} else if policy.Interval > 0 && expired == nil {
+					if timer == nil {
+						timer = time.NewTimer(policy.Interval)
+					} else {
+						timer.Reset(policy.Interval)
+					}
+					expired = timer.C
+				}
+			}
+		}
+	}()
+	return out
+}
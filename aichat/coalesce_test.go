@@ -0,0 +1,53 @@
+package aichat
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"chatlib/stream"
+)
+
+func TestCoalesceChunks(t *testing.T) {
+	boom := errors.New("boom")
+	chunks := make(chan StreamChunk, 10)
+	for _, c := range []StreamChunk{
+		{Content: "H"}, {Content: "i"}, {Content: "."}, {Content: " Y"}, {Content: "o"},
+		{Content: "!", FinishReason: "stop"}, {Content: "x"}, {Error: boom},
+	} {
+		chunks <- c
+	}
+	close(chunks)
+
+	var got []StreamChunk
+	for c := range CoalesceChunks(context.Background(), chunks, stream.FlushPolicy{Boundary: stream.SentenceBoundary}) {
+		got = append(got, c)
+	}
+	expected := []StreamChunk{
+		{Content: "Hi."}, {Content: " Yo!"}, {FinishReason: "stop"}, {Content: "x"}, {Error: boom},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+
+	// 零值策略不做合并
+	passthrough := make(chan StreamChunk)
+	if CoalesceChunks(context.Background(), passthrough, stream.FlushPolicy{}) != (<-chan StreamChunk)(passthrough) {
+		t.Error("Expected zero policy to return the input channel")
+	}
+}
+
+func TestCoalesceChunks_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	chunks := make(chan StreamChunk)
+	out := CoalesceChunks(ctx, chunks, stream.FlushPolicy{MaxBytes: 1})
+
+	chunks <- StreamChunk{Content: "a"}
+	// 消费者不再读取，取消后合并 goroutine 退出并继续读完上游
+	cancel()
+	chunks <- StreamChunk{Content: "b"}
+	close(chunks)
+	for range out {
+	}
+}
@@ -0,0 +1,184 @@
+package aichat
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultSummaryPrompt = "请将以下对话内容总结为简洁的摘要，保留关键事实、用户偏好和未完成的事项。"
+	defaultSummaryPrefix = "以下是此前对话的摘要：\n"
+	defaultSummaryCache  = 256
+)
+
+// Compactor 历史压缩器，历史超过阈值时用模型将较早的消息总结为一条摘要
+type Compactor struct {
+	Provider   ModelProvider // 生成摘要使用的模型，通常选用较便宜的模型
+	Model      string
+	Counter    TokenCounter
+	Threshold  int    // 历史消息超过该 token 数时触发压缩
+	KeepRecent int    // 保留最近的消息条数，不参与总结
+	Prompt     string // 摘要指令，为空时使用默认指令
+	CacheSize  int    // 摘要缓存条数，为 0 时使用默认值
+
+	cache map[string]string // 消息前缀哈希 -> 摘要
+	order []string
+	mu    sync.Mutex
+}
+
+// Compact 压缩请求历史：保留 system 消息和最近的消息，中间部分替换为摘要
+func (c *Compactor) Compact(ctx context.Context, req *ChatRequest) error {
+	count := func(msg ChatMessage) int {
+		return CountMessageTokens(c.Counter, msg)
+	}
+	if totalTokens(req.Messages, count) <= c.Threshold {
+		return nil
+	}
+
+	system, middle, recent := c.partition(req.Messages)
+	if len(middle) == 0 {
+		return nil
+	}
+
+	// 查找已缓存的最长前缀摘要
+	hashes := prefixHashes(middle)
+	cached, summary := 0, ""
+	for k := len(middle); k > 0; k-- {
+		if s, ok := c.lookup(hashes[k-1]); ok {
+			cached, summary = k, s
+			break
+		}
+	}
+
+	// 缓存摘要加上未总结的部分仍在阈值内时，无需重新总结
+	if cached > 0 && cached < len(middle) {
+		candidate := c.assemble(system, summary, middle[cached:], recent)
+		if totalTokens(candidate, count) <= c.Threshold {
+			req.Messages = candidate
+			return nil
+		}
+	}
+
+	if cached < len(middle) {
+		var err error
+		summary, err = c.summarize(ctx, summary, middle[cached:])
+		if err != nil {
+			return fmt.Errorf("summarize history: %w", err)
+		}
+		c.store(hashes[len(middle)-1], summary)
+	}
+
+	req.Messages = c.assemble(system, summary, nil, recent)
+	return nil
+}
+
+// partition 拆分出开头的 system 消息、待总结的消息和最近的消息，不拆开工具调用
+func (c *Compactor) partition(msgs []ChatMessage) (system, middle, recent []ChatMessage) {
+	start := 0
+	for start < len(msgs) && msgs[start].Role == "system" {
+		start++
+	}
+	system = msgs[:start]
+
+	groups := toolGroups(msgs[start:], func(ChatMessage) int { return 0 })
+	kept, split := 0, len(groups)
+	for split > 0 && kept < c.KeepRecent {
+		split--
+		kept += len(groups[split].messages)
+	}
+	if split == len(groups) && len(groups) > 0 {
+		// 至少保留最后一组作为当前提问
+		split = len(groups) - 1
+	}
+	return system, flatten(groups[:split]), flatten(groups[split:])
+}
+
+func (c *Compactor) assemble(system []ChatMessage, summary string, pending, recent []ChatMessage) []ChatMessage {
+	msgs := make([]ChatMessage, 0, len(system)+1+len(pending)+len(recent))
+	msgs = append(msgs, system...)
+	msgs = append(msgs, ChatMessage{Role: "system", Content: defaultSummaryPrefix + summary})
+	msgs = append(msgs, pending...)
+	return append(msgs, recent...)
+}
+
+// summarize 基于已有摘要和新增消息生成新的摘要
+func (c *Compactor) summarize(ctx context.Context, previous string, msgs []ChatMessage) (string, error) {
+	prompt := c.Prompt
+	if prompt == "" {
+		prompt = defaultSummaryPrompt
+	}
+
+	var transcript strings.Builder
+	if previous != "" {
+		transcript.WriteString(defaultSummaryPrefix + previous + "\n\n")
+	}
+	for _, msg := range msgs {
+		transcript.WriteString(msg.Role + ": " + msg.Content + "\n")
+		for _, call := range msg.ToolCalls {
+			transcript.WriteString(fmt.Sprintf("%s: call %s(%s)\n", msg.Role, call.Function.Name, call.Function.Arguments))
+		}
+	}
+
+	chunks, err := c.Provider.StreamChat(ctx, &ChatRequest{
+		Model: c.Model,
+		Messages: []ChatMessage{
+			{Role: "system", Content: prompt},
+			{Role: "user", Content: transcript.String()},
+		},
+		Stream: true,
+	})
+	if err != nil {
+		return "", err
+	}
+	summary, err := CollectStream(chunks)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(summary), nil
+}
+
+func (c *Compactor) lookup(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.cache[key]
+	return s, ok
+}
+
+func (c *Compactor) store(key, summary string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cache == nil {
+		c.cache = make(map[string]string)
+	}
+	if _, ok := c.cache[key]; !ok {
+		c.order = append(c.order, key)
+	}
+	c.cache[key] = summary
+
+	size := c.CacheSize
+	if size <= 0 {
+		size = defaultSummaryCache
+	}
+	for len(c.order) > size {
+		delete(c.cache, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+// prefixHashes 返回每个消息前缀的链式哈希，hashes[k] 对应 msgs[:k+1]
+func prefixHashes(msgs []ChatMessage) []string {
+	hashes := make([]string, len(msgs))
+	prev := []byte{}
+	for i, msg := range msgs {
+		data, _ := json.Marshal(msg)
+		sum := sha256.Sum256(append(prev, data...))
+		prev = sum[:]
+		hashes[i] = fmt.Sprintf("%x", sum)
+	}
+	return hashes
+}
@@ -0,0 +1,109 @@
+package aichat
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// summaryProvider 返回固定摘要并记录调用次数
+type summaryProvider struct {
+	calls int
+}
+
+func (p *summaryProvider) IsAvailable() bool {
+	return true
+}
+
+func (p *summaryProvider) StreamChat(ctx context.Context, req *ChatRequest) (<-chan StreamChunk, error) {
+	p.calls++
+	chunks := make(chan StreamChunk, 1)
+	chunks <- StreamChunk{Content: fmt.Sprintf("summary %d", p.calls), FinishReason: "stop"}
+	close(chunks)
+	return chunks, nil
+}
+
+func TestCompactor_Compact(t *testing.T) {
+	provider := &summaryProvider{}
+	c := &Compactor{Provider: provider, Counter: wordCounter{}, Threshold: 40, KeepRecent: 2}
+
+	history := []ChatMessage{
+		{Role: "system", Content: "sys"},
+		{Role: "user", Content: "q1 a b c d"},
+		{Role: "assistant", Content: "a1 a b c d"},
+		{Role: "user", Content: "q2 a b c d"},
+		{Role: "assistant", Content: "a2 a b c d"},
+		{Role: "user", Content: "q3"},
+	}
+
+	req := &ChatRequest{Messages: append([]ChatMessage(nil), history...)}
+	if err := c.Compact(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"system:sys", "system:" + defaultSummaryPrefix + "summary 1", "assistant:a2 a b c d", "user:q3"}
+	if got := roles(req.Messages); fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+
+	// 相同历史再次压缩应命中缓存
+	req = &ChatRequest{Messages: append([]ChatMessage(nil), history...)}
+	if err := c.Compact(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if provider.calls != 1 {
+		t.Errorf("Expected cached summary, got %d calls", provider.calls)
+	}
+
+	// 新增一轮后，缓存摘要加新消息仍在阈值内，不重新总结
+	history = append(history, ChatMessage{Role: "assistant", Content: "a3"}, ChatMessage{Role: "user", Content: "q4"})
+	req = &ChatRequest{Messages: append([]ChatMessage(nil), history...)}
+	if err := c.Compact(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if provider.calls != 1 {
+		t.Errorf("Expected cached summary to be reused, got %d calls", provider.calls)
+	}
+	if len(req.Messages) != 6 {
+		t.Errorf("Expected 6 messages, got %q", roles(req.Messages))
+	}
+
+	// 低于阈值时不压缩
+	req = &ChatRequest{Messages: history[:2]}
+	if err := c.Compact(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(req.Messages) != 2 {
+		t.Errorf("Expected history untouched, got %q", roles(req.Messages))
+	}
+}
+
+func TestCompactor_KeepRecent(t *testing.T) {
+	history := []ChatMessage{
+		{Role: "system", Content: "sys"},
+		{Role: "user", Content: "q1 a b c d e f g h"},
+		{Role: "assistant", Content: "a1 a b c d e f g h"},
+		{Role: "user", Content: "q2"},
+	}
+
+	tests := []struct {
+		name       string
+		keepRecent int
+		expected   []string
+	}{
+		{"Zero", 0, []string{"system:sys", "system:" + defaultSummaryPrefix + "summary 1", "user:q2"}},
+		{"Two", 2, []string{"system:sys", "system:" + defaultSummaryPrefix + "summary 1", "assistant:a1 a b c d e f g h", "user:q2"}},
+		{"CoversAll", 10, roles(history)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Compactor{Provider: &summaryProvider{}, Counter: wordCounter{}, Threshold: 10, KeepRecent: tt.keepRecent}
+			req := &ChatRequest{Messages: append([]ChatMessage(nil), history...)}
+			if err := c.Compact(context.Background(), req); err != nil {
+				t.Fatal(err)
+			}
+			if got := roles(req.Messages); fmt.Sprint(got) != fmt.Sprint(tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package aichat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+)
+
+// EmbeddingRequest 向量化请求
+type EmbeddingRequest struct {
+	Model      string   `json:"model"`
+	Input      []string `json:"input"`
+	Dimensions int      `json:"dimensions,omitempty"` // 输出维度，为 0 时使用模型默认维度
+}
+
+// EmbeddingUsage token 用量
+type EmbeddingUsage struct {
+	PromptTokens int `json:"prompt_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+// EmbeddingResponse 向量化响应
+type EmbeddingResponse struct {
+	Model      string         `json:"model"`
+	Embeddings [][]float32    `json:"embeddings"` // 与 Input 一一对应
+	Usage      EmbeddingUsage `json:"usage"`
+}
+
+// EmbeddingProvider 向量模型提供者接口
+type EmbeddingProvider interface {
+	// CreateEmbeddings 批量向量化，输入过多时由实现自动分批
+	CreateEmbeddings(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error)
+	// IsAvailable 检查模型是否可用
+	IsAvailable() bool
+}
+
+// Embedder 文本向量化接口
+type Embedder interface {
+	// Embed 返回与 input 一一对应的向量
+	Embed(ctx context.Context, input []string) ([][]float32, error)
+}
+
+// ModelEmbedder 将 EmbeddingProvider 绑定到指定模型和维度，实现 Embedder
+type ModelEmbedder struct {
+	Provider   EmbeddingProvider
+	Model      string
+	Dimensions int
+}
+
+func (e ModelEmbedder) Embed(ctx context.Context, input []string) ([][]float32, error) {
+	resp, err := e.Provider.CreateEmbeddings(ctx, &EmbeddingRequest{
+		Model:      e.Model,
+		Input:      input,
+		Dimensions: e.Dimensions,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Embeddings, nil
+}
+
+// CosineSimilarity 计算两个向量的余弦相似度，长度不同或为零向量时返回 0
+func CosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// embedBatches 将输入按 batchSize 分批调用 embed 并合并结果
+func embedBatches(req *EmbeddingRequest, batchSize int, embed func(input []string) (*EmbeddingResponse, error)) (*EmbeddingResponse, error) {
+	result := &EmbeddingResponse{Model: req.Model, Embeddings: make([][]float32, 0, len(req.Input))}
+	for start := 0; start < len(req.Input); start += batchSize {
+		end := min(start+batchSize, len(req.Input))
+		resp, err := embed(req.Input[start:end])
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Embeddings) != end-start {
+			return nil, fmt.Errorf("embedding count mismatch: expected %d, got %d", end-start, len(resp.Embeddings))
+		}
+		if resp.Model != "" {
+			result.Model = resp.Model
+		}
+		result.Embeddings = append(result.Embeddings, resp.Embeddings...)
+		result.Usage.PromptTokens += resp.Usage.PromptTokens
+		result.Usage.TotalTokens += resp.Usage.TotalTokens
+	}
+
+	// 上游忽略 dimensions 参数时在本地截断并归一化
+	if req.Dimensions > 0 {
+		for i, v := range result.Embeddings {
+			result.Embeddings[i] = truncateDimensions(v, req.Dimensions)
+		}
+	}
+	return result, nil
+}
+
+// truncateDimensions 截断向量到指定维度并重新做 L2 归一化
+func truncateDimensions(v []float32, dims int) []float32 {
+	if len(v) <= dims {
+		return v
+	}
+	v = v[:dims]
+
+	var norm float64
+	for _, x := range v {
+		norm += float64(x) * float64(x)
+	}
+	if norm == 0 {
+		return v
+	}
+	norm = math.Sqrt(norm)
+	for i := range v {
+		v[i] = float32(float64(v[i]) / norm)
+	}
+	return v
+}
+
+// postJSON 发送 JSON 请求并解析 JSON 响应
+func postJSON(ctx context.Context, client *http.Client, url, apiKey string, body, out any) error {
+	reqBody, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("API error: %s", string(body))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
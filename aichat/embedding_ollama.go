@@ -0,0 +1,65 @@
+package aichat
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const defaultOllamaEmbeddingBatch = 64
+
+// OllamaEmbeddingProvider Ollama 的 /api/embed 接口
+type OllamaEmbeddingProvider struct {
+	options   ProviderOptions
+	Client    *http.Client
+	BatchSize int // 单次请求的最大输入条数
+}
+
+func NewOllamaEmbeddingProvider(baseURL string) *OllamaEmbeddingProvider {
+	if baseURL == "" {
+		baseURL = "http://localhost:11434"
+	}
+	return &OllamaEmbeddingProvider{
+		options: ProviderOptions{
+			BaseURL: baseURL,
+		},
+		Client: &http.Client{
+			Timeout: 5 * time.Minute,
+		},
+		BatchSize: defaultOllamaEmbeddingBatch,
+	}
+}
+
+func (p *OllamaEmbeddingProvider) IsAvailable() bool {
+	return p.options.BaseURL != ""
+}
+
+type ollamaEmbeddingResponse struct {
+	Model           string      `json:"model"`
+	Embeddings      [][]float32 `json:"embeddings"`
+	PromptEvalCount int         `json:"prompt_eval_count"`
+}
+
+func (p *OllamaEmbeddingProvider) CreateEmbeddings(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	batchSize := p.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultOllamaEmbeddingBatch
+	}
+	url := strings.TrimSuffix(p.options.BaseURL, "/") + "/api/embed"
+
+	return embedBatches(req, batchSize, func(input []string) (*EmbeddingResponse, error) {
+		body := *req
+		body.Input = input
+
+		var resp ollamaEmbeddingResponse
+		if err := postJSON(ctx, p.Client, url, p.options.APIKey, body, &resp); err != nil {
+			return nil, err
+		}
+		return &EmbeddingResponse{
+			Model:      resp.Model,
+			Embeddings: resp.Embeddings,
+			Usage:      EmbeddingUsage{PromptTokens: resp.PromptEvalCount, TotalTokens: resp.PromptEvalCount},
+		}, nil
+	})
+}
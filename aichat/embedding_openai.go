@@ -0,0 +1,119 @@
+package aichat
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const defaultOpenAIEmbeddingBatch = 512
+
+// OpenAIEmbeddingProvider OpenAI 兼容的 /embeddings 接口
+type OpenAIEmbeddingProvider struct {
+	options   ProviderOptions
+	Client    *http.Client
+	BatchSize int  // 单次请求的最大输入条数
+	Base64    bool // 以 base64 格式传输向量，减小响应体积
+}
+
+func NewOpenAIEmbeddingProvider(apiKey, baseURL string) *OpenAIEmbeddingProvider {
+	if baseURL == "" {
+		baseURL = "https://api.openai.com/v1"
+	}
+	return &OpenAIEmbeddingProvider{
+		options: ProviderOptions{
+			BaseURL: baseURL,
+			APIKey:  apiKey,
+		},
+		Client: &http.Client{
+			Timeout: 2 * time.Minute,
+		},
+		BatchSize: defaultOpenAIEmbeddingBatch,
+		Base64:    true,
+	}
+}
+
+func (p *OpenAIEmbeddingProvider) IsAvailable() bool {
+	return p.options.APIKey != ""
+}
+
+type openAIEmbeddingRequest struct {
+	EmbeddingRequest
+	EncodingFormat string `json:"encoding_format,omitempty"`
+}
+
+type openAIEmbeddingResponse struct {
+	Model string `json:"model"`
+	Data  []struct {
+		Index     int             `json:"index"`
+		Embedding json.RawMessage `json:"embedding"` // []float 或 base64 字符串
+	} `json:"data"`
+	Usage EmbeddingUsage `json:"usage"`
+}
+
+func (p *OpenAIEmbeddingProvider) CreateEmbeddings(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	batchSize := p.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultOpenAIEmbeddingBatch
+	}
+	url := strings.TrimSuffix(p.options.BaseURL, "/") + "/embeddings"
+
+	return embedBatches(req, batchSize, func(input []string) (*EmbeddingResponse, error) {
+		body := openAIEmbeddingRequest{EmbeddingRequest: *req}
+		body.Input = input
+		if p.Base64 {
+			body.EncodingFormat = "base64"
+		}
+
+		var resp openAIEmbeddingResponse
+		if err := postJSON(ctx, p.Client, url, p.options.APIKey, body, &resp); err != nil {
+			return nil, err
+		}
+
+		sort.Slice(resp.Data, func(i, j int) bool { return resp.Data[i].Index < resp.Data[j].Index })
+		result := &EmbeddingResponse{Model: resp.Model, Usage: resp.Usage}
+		for _, d := range resp.Data {
+			v, err := decodeEmbedding(d.Embedding)
+			if err != nil {
+				return nil, err
+			}
+			result.Embeddings = append(result.Embeddings, v)
+		}
+		return result, nil
+	})
+}
+
+// decodeEmbedding 解析浮点数组或 base64 编码（小端 float32）的向量
+func decodeEmbedding(raw json.RawMessage) ([]float32, error) {
+	if len(raw) > 0 && raw[0] == '"' {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, err
+		}
+		data, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, err
+		}
+		if len(data)%4 != 0 {
+			return nil, fmt.Errorf("invalid base64 embedding length %d", len(data))
+		}
+		v := make([]float32, len(data)/4)
+		for i := range v {
+			v[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:]))
+		}
+		return v, nil
+	}
+
+	var v []float32
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
@@ -0,0 +1,112 @@
+package aichat
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// embedValue 测试用向量：第 i 条输入的向量为 [len(input[i]), 0, 0]
+func embedValue(text string) []float32 {
+	return []float32{float32(len(text)), 0, 0}
+}
+
+func encodeBase64(v []float32) string {
+	data := make([]byte, len(v)*4)
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(data[i*4:], math.Float32bits(x))
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func TestOpenAIEmbeddingProvider_CreateEmbeddings(t *testing.T) {
+	var batches []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/embeddings" || r.Header.Get("Authorization") != "Bearer key" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		var req openAIEmbeddingRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		batches = append(batches, len(req.Input))
+
+		var data []map[string]any
+		// 倒序返回，验证按 index 排序
+		for i := len(req.Input) - 1; i >= 0; i-- {
+			var embedding any = embedValue(req.Input[i])
+			if req.EncodingFormat == "base64" {
+				embedding = encodeBase64(embedValue(req.Input[i]))
+			}
+			data = append(data, map[string]any{"index": i, "embedding": embedding})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"model": req.Model,
+			"data":  data,
+			"usage": map[string]int{"prompt_tokens": len(req.Input), "total_tokens": len(req.Input)},
+		})
+	}))
+	defer server.Close()
+
+	for _, useBase64 := range []bool{false, true} {
+		batches = nil
+		provider := NewOpenAIEmbeddingProvider("key", server.URL+"/v1")
+		provider.BatchSize = 2
+		provider.Base64 = useBase64
+
+		input := []string{"a", "bb", "ccc", "dddd", "eeeee"}
+		resp, err := provider.CreateEmbeddings(context.Background(), &EmbeddingRequest{Model: "text-embedding-3-small", Input: input})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(batches) != 3 {
+			t.Errorf("Expected 3 batches, got %v", batches)
+		}
+		if resp.Usage.TotalTokens != 5 {
+			t.Errorf("Expected usage to be summed, got %+v", resp.Usage)
+		}
+		for i, text := range input {
+			if resp.Embeddings[i][0] != float32(len(text)) {
+				t.Errorf("base64=%v: expected embedding %d to be %v, got %v", useBase64, i, embedValue(text), resp.Embeddings[i])
+			}
+		}
+	}
+}
+
+func TestOllamaEmbeddingProvider_CreateEmbeddings(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req EmbeddingRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		var embeddings [][]float32
+		for _, text := range req.Input {
+			embeddings = append(embeddings, []float32{float32(len(text)), 3, 4})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"model": req.Model, "embeddings": embeddings})
+	}))
+	defer server.Close()
+
+	factory := NewDefaultModelFactory()
+	factory.RegisterEmbeddingProvider("nomic-embed-text", NewOllamaEmbeddingProvider(server.URL))
+	provider, err := factory.GetEmbeddingProvider("nomic-embed-text")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 上游返回 3 维，请求 2 维时本地截断并归一化
+	embedder := ModelEmbedder{Provider: provider, Model: "nomic-embed-text", Dimensions: 2}
+	vectors, err := embedder.Embed(context.Background(), []string{"abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors[0]) != 2 || math.Abs(float64(vectors[0][0])-0.7071) > 1e-3 {
+		t.Errorf("Expected normalized 2-dim vector, got %v", vectors[0])
+	}
+
+	if _, err = factory.GetEmbeddingProvider("missing"); err == nil {
+		t.Error("Expected error for unknown embedding model")
+	}
+}
@@ -6,13 +6,15 @@ import (
 )
 
 type DefaultModelFactory struct {
-	providers map[string]ModelProvider
-	mu        sync.RWMutex
+	providers  map[string]ModelProvider
+	embeddings map[string]EmbeddingProvider
+	mu         sync.RWMutex
 }
 
 func NewDefaultModelFactory() *DefaultModelFactory {
 	return &DefaultModelFactory{
-		providers: make(map[string]ModelProvider),
+		providers:  make(map[string]ModelProvider),
+		embeddings: make(map[string]EmbeddingProvider),
 	}
 }
 
@@ -50,3 +52,38 @@ func (f *DefaultModelFactory) ListAvailableModels() []string {
 	}
 	return models
 }
+
+func (f *DefaultModelFactory) RegisterEmbeddingProvider(name string, provider EmbeddingProvider) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.embeddings[name] = provider
+}
+
+func (f *DefaultModelFactory) GetEmbeddingProvider(modelName string) (EmbeddingProvider, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	provider, ok := f.embeddings[modelName]
+	if !ok {
+		return nil, fmt.Errorf("embedding model %s not found", modelName)
+	}
+
+	if !provider.IsAvailable() {
+		return nil, fmt.Errorf("embedding model %s is not available", modelName)
+	}
+
+	return provider, nil
+}
+
+func (f *DefaultModelFactory) ListAvailableEmbeddingModels() []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	var models []string
+	for modelName, provider := range f.embeddings {
+		if provider.IsAvailable() {
+			models = append(models, modelName)
+		}
+	}
+	return models
+}
@@ -1,13 +1,14 @@
 package aichat
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+
+	"chatlib/stream"
 )
 
 type ProviderOptions struct {
@@ -16,8 +17,9 @@ type ProviderOptions struct {
 }
 
 type BaseProvider struct {
-	options ProviderOptions
-	Client  *http.Client
+	options      ProviderOptions
+	Client       *http.Client
+	MaxEventSize int // 单个 SSE 事件的最大字节数，0 使用 stream.DefaultMaxEventSize
 }
 
 func (p BaseProvider) StreamChat(ctx context.Context, request *ChatRequest) (<-chan StreamChunk, error) {
@@ -52,7 +54,8 @@ func (p BaseProvider) StreamChat(ctx context.Context, request *ChatRequest) (<-c
 			return
 		}
 
-		scanner := bufio.NewScanner(resp.Body)
+		decoder := stream.NewDecoder(resp.Body)
+		decoder.MaxEventSize = p.MaxEventSize
 		for {
 			select {
 			case <-ctx.Done():
@@ -60,32 +63,35 @@ func (p BaseProvider) StreamChat(ctx context.Context, request *ChatRequest) (<-c
 				chunks <- StreamChunk{Error: fmt.Errorf("streamer read timeout: %v", ctx.Err())}
 				return
 			default:
-				if !scanner.Scan() {
-					if err = scanner.Err(); err != nil {
+				var msg stream.SSEMessage
+				msg, err = decoder.Decode()
+				if err != nil {
+					if err != io.EOF {
 						chunks <- StreamChunk{Error: err}
 					}
 					return
 				}
 
-				line := scanner.Text()
-				if len(line) > 6 && line[:6] == "data: " {
-					data := line[6:]
-					if data == "[DONE]" {
-						return
-					}
+				if msg.Data == "[DONE]" {
+					return
+				}
+				// 部分厂商以 error 事件返回流中错误
+				if msg.Event == "error" {
+					chunks <- StreamChunk{Error: fmt.Errorf("API error: %s", msg.Data)}
+					return
+				}
 
-					// 解析JSON
-					var chatResp ChatResponse
-					if err = json.Unmarshal([]byte(data), &chatResp); err != nil {
-						continue
-					}
+				// 解析JSON
+				var chatResp ChatResponse
+				if err = json.Unmarshal([]byte(msg.Data), &chatResp); err != nil {
+					continue
+				}
 
-					// 提取内容
-					if len(chatResp.Choices) > 0 && chatResp.Choices[0].Delta.Content != "" {
-						chunks <- StreamChunk{
-							Content:      chatResp.Choices[0].Delta.Content,
-							FinishReason: chatResp.Choices[0].FinishReason,
-						}
+				// 提取内容
+				if len(chatResp.Choices) > 0 && (chatResp.Choices[0].Delta.Content != "" || chatResp.Choices[0].FinishReason != "") {
+					chunks <- StreamChunk{
+						Content:      chatResp.Choices[0].Delta.Content,
+						FinishReason: chatResp.Choices[0].FinishReason,
 					}
 				}
 			}
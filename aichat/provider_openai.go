@@ -2,6 +2,7 @@ package aichat
 
 import (
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -9,6 +10,7 @@ type OpenAIProvider struct {
 	BaseProvider
 }
 
+// NewOpenAIProvider baseURL 为 API 根地址（如 https://api.openai.com/v1），请求发往其下的 /chat/completions
 func NewOpenAIProvider(apiKey, baseURL string) *OpenAIProvider {
 	if baseURL == "" {
 		baseURL = "https://api.openai.com/v1"
@@ -16,7 +18,7 @@ func NewOpenAIProvider(apiKey, baseURL string) *OpenAIProvider {
 	return &OpenAIProvider{
 		BaseProvider{
 			options: ProviderOptions{
-				BaseURL: baseURL,
+				BaseURL: strings.TrimSuffix(baseURL, "/") + "/chat/completions",
 				APIKey:  apiKey,
 			},
 			Client: &http.Client{
@@ -2,11 +2,16 @@ package aichat
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
+
+	"chatlib/stream"
 )
 
 func TestBaseProvider_StreamChat(t *testing.T) {
@@ -70,3 +75,80 @@ func TestBaseProvider_StreamChat(t *testing.T) {
 		}
 	})
 }
+
+func TestBaseProvider_SSEVariants(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		// 无空格的 data、注释、命名事件、CRLF 换行
+		fmt.Fprint(w, ": keep-alive\r\n\r\n")
+		fmt.Fprint(w, "data:{\"choices\":[{\"delta\":{\"content\":\"Hel\"}}]}\r\n\r\n")
+		fmt.Fprint(w, "event: completion\nid: 2\ndata: {\"choices\":[{\"delta\":{\"content\":\"lo\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"ignored\"}}]}\n\n")
+	}))
+	defer server.Close()
+
+	// baseURL 为 API 根地址，末尾的 / 可有可无
+	provider := NewOpenAIProvider("key", server.URL+"/v1/")
+	chunks, err := provider.StreamChat(context.Background(), &ChatRequest{Model: "m", Stream: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var content, finishReason string
+	for chunk := range chunks {
+		if chunk.Error != nil {
+			t.Fatal(chunk.Error)
+		}
+		content += chunk.Content
+		if chunk.FinishReason != "" {
+			finishReason = chunk.FinishReason
+		}
+	}
+	if content != "Hello" || finishReason != "stop" {
+		t.Errorf("Expected Hello/stop, got %q/%q", content, finishReason)
+	}
+}
+
+func TestBaseProvider_ErrorEvent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "event: error\ndata: {\"message\":\"overloaded\"}\n\n")
+	}))
+	defer server.Close()
+
+	chunks, err := NewOpenAIProvider("key", server.URL).StreamChat(context.Background(), &ChatRequest{Model: "m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = CollectStream(chunks); err == nil || !strings.Contains(err.Error(), "overloaded") {
+		t.Errorf("Expected overloaded error, got %v", err)
+	}
+}
+
+func TestBaseProvider_LargeEvent(t *testing.T) {
+	args := strings.Repeat("a", 200*1024)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\n", args)
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	provider := NewOpenAIProvider("key", server.URL)
+	chunks, _ := provider.StreamChat(context.Background(), &ChatRequest{Model: "m"})
+	if content, err := CollectStream(chunks); err != nil || content != args {
+		t.Fatalf("Expected large chunk to be decoded, got %d bytes, %v", len(content), err)
+	}
+
+	provider.MaxEventSize = 64 * 1024
+	chunks, _ = provider.StreamChat(context.Background(), &ChatRequest{Model: "m"})
+	_, err := CollectStream(chunks)
+	var tooLarge *stream.EventTooLargeError
+	if !errors.As(err, &tooLarge) {
+		t.Errorf("Expected EventTooLargeError, got %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package aichat
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultSemanticThreshold  = 0.95
+	defaultSemanticMaxEntries = 1000
+)
+
+// SemanticCacheOptions 语义缓存配置
+type SemanticCacheOptions struct {
+	Threshold   float64       // 余弦相似度阈值，为 0 时使用默认值
+	TTL         time.Duration // 缓存有效期，为 0 表示不过期
+	MaxEntries  int           // 最大条数，为 0 时使用默认值
+	ReplayChunk int           // 回放时每个块的字符数
+	ReplayDelay time.Duration // 回放时块之间的间隔
+}
+
+// CacheStats 缓存命中统计
+type CacheStats struct {
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+	Entries int   `json:"entries"`
+}
+
+type semanticEntry struct {
+	scope     string
+	vector    []float32
+	entry     *CacheEntry
+	expiresAt time.Time
+}
+
+// SemanticCachingProvider 语义响应缓存，最后一条用户消息与已缓存问题足够相似时直接回放答案
+//
+// 缓存按模型和 system 提示词划分作用域，只缓存 temperature 为 0 的请求。
+type SemanticCachingProvider struct {
+	provider ModelProvider
+	embedder Embedder
+	options  SemanticCacheOptions
+
+	entries []*semanticEntry // 按写入顺序，超出上限时淘汰最早的
+	hits    atomic.Int64
+	misses  atomic.Int64
+	mu      sync.RWMutex
+}
+
+func NewSemanticCachingProvider(provider ModelProvider, embedder Embedder, options SemanticCacheOptions) *SemanticCachingProvider {
+	if options.Threshold == 0 {
+		options.Threshold = defaultSemanticThreshold
+	}
+	if options.MaxEntries <= 0 {
+		options.MaxEntries = defaultSemanticMaxEntries
+	}
+	if options.ReplayChunk <= 0 {
+		options.ReplayChunk = defaultReplayChunk
+	}
+	return &SemanticCachingProvider{
+		provider: provider,
+		embedder: embedder,
+		options:  options,
+	}
+}
+
+func (p *SemanticCachingProvider) IsAvailable() bool {
+	return p.provider.IsAvailable()
+}
+
+// Stats 返回命中统计
+func (p *SemanticCachingProvider) Stats() CacheStats {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return CacheStats{
+		Hits:    p.hits.Load(),
+		Misses:  p.misses.Load(),
+		Entries: len(p.entries),
+	}
+}
+
+func (p *SemanticCachingProvider) StreamChat(ctx context.Context, req *ChatRequest) (<-chan StreamChunk, error) {
+	question, ok := lastUserMessage(req)
+	if !ok || req.Temperature != 0 {
+		return p.provider.StreamChat(ctx, req)
+	}
+
+	vectors, err := p.embedder.Embed(ctx, []string{question})
+	if err != nil || len(vectors) != 1 {
+		// 向量化失败时降级为直接请求上游
+		p.misses.Add(1)
+		return p.provider.StreamChat(ctx, req)
+	}
+	scope := semanticScope(req)
+	vector := vectors[0]
+
+	if entry := p.lookup(scope, vector); entry != nil {
+		p.hits.Add(1)
+		return replayEntry(ctx, entry, p.options.ReplayChunk, p.options.ReplayDelay), nil
+	}
+	p.misses.Add(1)
+
+	upstream, err := p.provider.StreamChat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return forwardAndCollect(ctx, upstream, func(entry *CacheEntry) {
+		p.store(scope, vector, entry)
+	}), nil
+}
+
+// lookup 在作用域内查找相似度最高且超过阈值的条目
+func (p *SemanticCachingProvider) lookup(scope string, vector []float32) *CacheEntry {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	now := time.Now()
+	var best *CacheEntry
+	bestScore := p.options.Threshold
+	for _, e := range p.entries {
+		if e.scope != scope || (!e.expiresAt.IsZero() && now.After(e.expiresAt)) {
+			continue
+		}
+		if score := CosineSimilarity(vector, e.vector); score >= bestScore {
+			best, bestScore = e.entry, score
+		}
+	}
+	return best
+}
+
+func (p *SemanticCachingProvider) store(scope string, vector []float32, entry *CacheEntry) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	item := &semanticEntry{scope: scope, vector: vector, entry: entry}
+	if p.options.TTL > 0 {
+		item.expiresAt = time.Now().Add(p.options.TTL)
+	}
+
+	// 顺带清理过期条目
+	now := time.Now()
+	entries := p.entries[:0]
+	for _, e := range p.entries {
+		if e.expiresAt.IsZero() || now.Before(e.expiresAt) {
+			entries = append(entries, e)
+		}
+	}
+	p.entries = append(entries, item)
+	if over := len(p.entries) - p.options.MaxEntries; over > 0 {
+		p.entries = p.entries[over:]
+	}
+}
+
+// semanticScope 缓存作用域：模型名 + system 提示词的哈希
+func semanticScope(req *ChatRequest) string {
+	h := sha256.New()
+	for _, msg := range req.Messages {
+		if msg.Role == "system" {
+			h.Write([]byte(msg.Content))
+			h.Write([]byte{0})
+		}
+	}
+	return req.Model + ":" + hex.EncodeToString(h.Sum(nil))
+}
+
+func lastUserMessage(req *ChatRequest) (string, bool) {
+	if len(req.Messages) == 0 {
+		return "", false
+	}
+	last := req.Messages[len(req.Messages)-1]
+	return last.Content, last.Role == "user"
+}
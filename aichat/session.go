@@ -0,0 +1,391 @@
+package aichat
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrSessionBusy = errors.New("session is generating a reply")
+
+// SessionOptions 会话配置
+type SessionOptions struct {
+	Model       string
+	Provider    ModelProvider
+	Store       SessionStore
+	System      string         // 系统提示词，不持久化，每次请求时置于最前
+	Temperature float64        // 采样温度
+	MaxTokens   int            // 回复最大 token 数
+	Compactor   *Compactor     // 可选，历史过长时压缩
+	Window      *ContextWindow // 可选，发送前按上下文窗口裁剪
+}
+
+// Session 会话，负责维护消息历史、流式获取回复并持久化
+//
+// 消息以树的形式保存：编辑或重新生成会在原消息旁创建兄弟节点，
+// head 指向当前分支的末端，从 head 回溯到根即为发送给模型的消息序列。
+type Session struct {
+	ID      string
+	options SessionOptions
+
+	nodes    map[string]SessionMessage
+	children map[string][]string // 父消息 ID -> 子消息 ID，按创建顺序
+	head     string
+	busy     bool
+	mu       sync.Mutex
+}
+
+func NewSession(id string, options SessionOptions) *Session {
+	return &Session{
+		ID:       id,
+		options:  options,
+		nodes:    make(map[string]SessionMessage),
+		children: make(map[string][]string),
+	}
+}
+
+// LoadSession 从存储中恢复会话，当前分支为最后一次追加消息或切换分支后的分支
+func LoadSession(ctx context.Context, id string, options SessionOptions) (*Session, error) {
+	msgs, err := options.Store.Load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	s := NewSession(id, options)
+	for _, msg := range msgs {
+		if msg.Active != "" {
+			if _, ok := s.nodes[msg.Active]; ok {
+				s.head = msg.Active
+			}
+			continue
+		}
+		s.add(msg)
+	}
+	return s, nil
+}
+
+// Messages 返回当前分支的消息序列
+func (s *Session) Messages() []ChatMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var msgs []ChatMessage
+	for _, msg := range s.path() {
+		msgs = append(msgs, msg.ChatMessage)
+	}
+	return msgs
+}
+
+// Head 返回当前分支末端的消息 ID
+func (s *Session) Head() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.head
+}
+
+// Children 返回消息的子消息（即各分支），messageID 为空时返回根消息
+func (s *Session) Children(messageID string) []SessionMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var msgs []SessionMessage
+	for _, id := range s.children[messageID] {
+		msgs = append(msgs, s.nodes[id])
+	}
+	return msgs
+}
+
+// Send 在当前分支末端追加用户消息并流式获取回复，流结束后回复会被追加并持久化
+func (s *Session) Send(ctx context.Context, content string) (<-chan StreamChunk, error) {
+	s.mu.Lock()
+	if s.busy {
+		s.mu.Unlock()
+		return nil, ErrSessionBusy
+	}
+	prompt := newSessionMessage(s.head, ChatMessage{Role: "user", Content: content})
+	history := s.begin(prompt.ParentID)
+	s.mu.Unlock()
+
+	return s.generate(ctx, &prompt, "", history)
+}
+
+// Edit 编辑一条用户消息：在其父消息下创建新的用户消息分支并重新获取回复
+func (s *Session) Edit(ctx context.Context, messageID, content string) (<-chan StreamChunk, error) {
+	s.mu.Lock()
+	if s.busy {
+		s.mu.Unlock()
+		return nil, ErrSessionBusy
+	}
+	msg, ok := s.nodes[messageID]
+	if !ok {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("message %s not found", messageID)
+	}
+	if msg.Role != "user" {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("message %s is not a user message", messageID)
+	}
+	prompt := newSessionMessage(msg.ParentID, ChatMessage{Role: "user", Content: content})
+	history := s.begin(prompt.ParentID)
+	s.mu.Unlock()
+
+	return s.generate(ctx, &prompt, "", history)
+}
+
+// Regenerate 重新生成当前分支的最后一条回复，新回复作为原回复的兄弟分支
+func (s *Session) Regenerate(ctx context.Context) (<-chan StreamChunk, error) {
+	s.mu.Lock()
+	if s.busy {
+		s.mu.Unlock()
+		return nil, ErrSessionBusy
+	}
+	head, ok := s.nodes[s.head]
+	if !ok {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("session %s has no messages", s.ID)
+	}
+	parentID := head.ID
+	if head.Role == "assistant" {
+		parentID = head.ParentID
+	}
+	history := s.begin(parentID)
+	s.mu.Unlock()
+
+	return s.generate(ctx, nil, parentID, history)
+}
+
+// Fork 将当前分支末端移到指定消息，后续 Send 将从该消息处分叉
+func (s *Session) Fork(ctx context.Context, messageID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.busy {
+		return ErrSessionBusy
+	}
+	if _, ok := s.nodes[messageID]; !ok {
+		return fmt.Errorf("message %s not found", messageID)
+	}
+	return s.setHead(ctx, messageID)
+}
+
+// SwitchBranch 切换到包含指定消息的分支，并沿最新的子消息走到分支末端
+func (s *Session) SwitchBranch(ctx context.Context, messageID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.busy {
+		return ErrSessionBusy
+	}
+	if _, ok := s.nodes[messageID]; !ok {
+		return fmt.Errorf("message %s not found", messageID)
+	}
+	for {
+		children := s.children[messageID]
+		if len(children) == 0 {
+			break
+		}
+		messageID = children[len(children)-1]
+	}
+	return s.setHead(ctx, messageID)
+}
+
+// setHead 持久化分支切换记录并移动分支末端，调用方需持有锁
+func (s *Session) setHead(ctx context.Context, messageID string) error {
+	if messageID == s.head {
+		return nil
+	}
+	if err := s.options.Store.Append(ctx, s.ID, SessionMessage{Active: messageID, CreatedAt: time.Now()}); err != nil {
+		return err
+	}
+	s.head = messageID
+	return nil
+}
+
+// begin 标记会话忙碌，返回从根到 parentID 的消息快照，调用方需持有锁
+func (s *Session) begin(parentID string) []ChatMessage {
+	s.busy = true
+	var history []ChatMessage
+	for _, msg := range s.pathTo(parentID) {
+		history = append(history, msg.ChatMessage)
+	}
+	return history
+}
+
+// generate 基于 history 发起请求，调用方需已通过 begin 标记忙碌且不持有锁。
+// 压缩和打开上游流都在锁外进行；prompt 非空时在上游流打开后持久化并挂到分支上，
+// 回复挂在 prompt 之下，否则挂在 parentID 之下。失败时只需解除忙碌
+func (s *Session) generate(ctx context.Context, prompt *SessionMessage, parentID string, history []ChatMessage) (<-chan StreamChunk, error) {
+	fail := func(err error) (<-chan StreamChunk, error) {
+		s.mu.Lock()
+		s.busy = false
+		s.mu.Unlock()
+		return nil, err
+	}
+	if prompt != nil {
+		history = append(history, prompt.ChatMessage)
+		parentID = prompt.ID
+	}
+
+	req, err := s.buildRequest(ctx, history)
+	if err != nil {
+		return fail(err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	upstream, err := s.options.Provider.StreamChat(ctx, req)
+	if err != nil {
+		cancel()
+		return fail(err)
+	}
+	if prompt != nil {
+		s.mu.Lock()
+		if err = s.options.Store.Append(ctx, s.ID, *prompt); err == nil {
+			s.add(*prompt)
+		}
+		s.mu.Unlock()
+		if err != nil {
+			cancel()
+			go drainChunks(upstream)
+			return fail(err)
+		}
+	}
+
+	chunks := make(chan StreamChunk, 100)
+	go func() {
+		defer close(chunks)
+		defer cancel()
+
+		var reply strings.Builder
+		var streamErr error
+		forward := true
+		for chunk := range upstream {
+			if chunk.Error != nil {
+				streamErr = chunk.Error
+			}
+			reply.WriteString(chunk.Content)
+			if !forward {
+				continue
+			}
+			select {
+			case chunks <- chunk:
+			case <-ctx.Done():
+				// 调用方已放弃读取，继续消费上游直到结束，保证 finish 执行
+				forward = false
+			}
+		}
+
+		if streamErr == nil {
+			// 上游因取消提前结束时也视为不完整
+			streamErr = ctx.Err()
+		}
+		if err := s.finish(ctx, parentID, reply.String(), streamErr); err != nil {
+			select {
+			case chunks <- StreamChunk{Error: err}:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return chunks, nil
+}
+
+// finish 结束生成，成功时持久化回复，出错时丢弃不完整的回复
+func (s *Session) finish(ctx context.Context, parentID, reply string, streamErr error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.busy = false
+
+	if streamErr != nil {
+		return nil
+	}
+	return s.appendMessage(context.WithoutCancel(ctx), parentID, ChatMessage{Role: "assistant", Content: reply})
+}
+
+// appendMessage 持久化新消息并将其设为分支末端，调用方需持有锁
+func (s *Session) appendMessage(ctx context.Context, parentID string, msg ChatMessage) error {
+	node := newSessionMessage(parentID, msg)
+	if err := s.options.Store.Append(ctx, s.ID, node); err != nil {
+		return err
+	}
+	s.add(node)
+	return nil
+}
+
+func newSessionMessage(parentID string, msg ChatMessage) SessionMessage {
+	return SessionMessage{
+		ID:          newMessageID(),
+		ParentID:    parentID,
+		ChatMessage: msg,
+		CreatedAt:   time.Now(),
+	}
+}
+
+func (s *Session) add(msg SessionMessage) {
+	s.nodes[msg.ID] = msg
+	s.children[msg.ParentID] = append(s.children[msg.ParentID], msg.ID)
+	s.head = msg.ID
+}
+
+// drainChunks 丢弃剩余的块，避免上游 goroutine 阻塞
+func drainChunks(chunks <-chan StreamChunk) {
+	for range chunks {
+	}
+}
+
+// path 返回从根到 head 的消息，调用方需持有锁
+func (s *Session) path() []SessionMessage {
+	return s.pathTo(s.head)
+}
+
+// pathTo 返回从根到指定消息的消息，调用方需持有锁
+func (s *Session) pathTo(messageID string) []SessionMessage {
+	var msgs []SessionMessage
+	for id := messageID; id != ""; {
+		msg, ok := s.nodes[id]
+		if !ok {
+			break
+		}
+		msgs = append(msgs, msg)
+		id = msg.ParentID
+	}
+	for i, j := 0, len(msgs)-1; i < j; i, j = i+1, j-1 {
+		msgs[i], msgs[j] = msgs[j], msgs[i]
+	}
+	return msgs
+}
+
+// buildRequest 由消息快照构建请求，压缩时会调用模型，不能持有锁
+func (s *Session) buildRequest(ctx context.Context, history []ChatMessage) (*ChatRequest, error) {
+	req := &ChatRequest{
+		Model:       s.options.Model,
+		Stream:      true,
+		Temperature: s.options.Temperature,
+		MaxTokens:   s.options.MaxTokens,
+	}
+	if s.options.System != "" {
+		req.Messages = append(req.Messages, ChatMessage{Role: "system", Content: s.options.System})
+	}
+	req.Messages = append(req.Messages, history...)
+
+	if s.options.Compactor != nil {
+		if err := s.options.Compactor.Compact(ctx, req); err != nil {
+			return nil, err
+		}
+	}
+	if s.options.Window != nil {
+		if err := s.options.Window.Fit(req); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+func newMessageID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,156 @@
+package aichat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SessionMessage 会话中持久化的消息
+type SessionMessage struct {
+	ID       string `json:"id"`
+	ParentID string `json:"parent_id,omitempty"` // 父消息 ID，为空表示根消息
+	ChatMessage
+	CreatedAt time.Time `json:"created_at"`
+	Active    string    `json:"active,omitempty"` // 非空时为分支切换记录，不是消息：当前分支末端移到该消息
+}
+
+// SessionStore 会话存储接口，消息只追加不修改
+type SessionStore interface {
+	// Append 追加消息
+	Append(ctx context.Context, sessionID string, msgs ...SessionMessage) error
+	// Load 按追加顺序加载会话的全部消息，会话不存在时返回空
+	Load(ctx context.Context, sessionID string) ([]SessionMessage, error)
+	// Delete 删除会话
+	Delete(ctx context.Context, sessionID string) error
+}
+
+// MemorySessionStore 内存会话存储
+type MemorySessionStore struct {
+	sessions map[string][]SessionMessage
+	mu       sync.RWMutex
+}
+
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{
+		sessions: make(map[string][]SessionMessage),
+	}
+}
+
+func (s *MemorySessionStore) Append(ctx context.Context, sessionID string, msgs ...SessionMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[sessionID] = append(s.sessions[sessionID], msgs...)
+	return nil
+}
+
+func (s *MemorySessionStore) Load(ctx context.Context, sessionID string) ([]SessionMessage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]SessionMessage(nil), s.sessions[sessionID]...), nil
+}
+
+func (s *MemorySessionStore) Delete(ctx context.Context, sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, sessionID)
+	return nil
+}
+
+// FileSessionStore 文件会话存储，每个会话一个 JSONL 文件
+type FileSessionStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+func NewFileSessionStore(dir string) (*FileSessionStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileSessionStore{dir: dir}, nil
+}
+
+func (s *FileSessionStore) Append(ctx context.Context, sessionID string, msgs ...SessionMessage) error {
+	path, err := s.path(sessionID)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	for _, msg := range msgs {
+		if err = enc.Encode(msg); err != nil {
+			return err
+		}
+	}
+	return f.Sync()
+}
+
+func (s *FileSessionStore) Load(ctx context.Context, sessionID string) ([]SessionMessage, error) {
+	path, err := s.path(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var msgs []SessionMessage
+	dec := json.NewDecoder(f)
+	for {
+		var msg SessionMessage
+		if err = dec.Decode(&msg); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("session %s is corrupted: %v", sessionID, err)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+func (s *FileSessionStore) Delete(ctx context.Context, sessionID string) error {
+	path, err := s.path(sessionID)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err = os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *FileSessionStore) path(sessionID string) (string, error) {
+	if sessionID == "" || sessionID == "." || sessionID == ".." || strings.ContainsAny(sessionID, `/\`) {
+		return "", fmt.Errorf("invalid session id %q", sessionID)
+	}
+	return filepath.Join(s.dir, sessionID+".jsonl"), nil
+}
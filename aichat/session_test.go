@@ -0,0 +1,351 @@
+package aichat
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSession_Send(t *testing.T) {
+	fileStore, err := NewFileSessionStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stores := map[string]SessionStore{
+		"MemoryStore": NewMemorySessionStore(),
+		"FileStore":   fileStore,
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			options := SessionOptions{Model: "mock", Provider: &summaryProvider{}, Store: store, System: "sys"}
+			session := NewSession("s1", options)
+
+			for _, question := range []string{"q1", "q2"} {
+				chunks, err := session.Send(ctx, question)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err = CollectStream(chunks); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			expected := []string{"user:q1", "assistant:summary 1", "user:q2", "assistant:summary 2"}
+			if got := roles(session.Messages()); !reflect.DeepEqual(got, expected) {
+				t.Errorf("Expected %q, got %q", expected, got)
+			}
+
+			loaded, err := LoadSession(ctx, "s1", options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := roles(loaded.Messages()); !reflect.DeepEqual(got, expected) {
+				t.Errorf("Expected %q after reload, got %q", expected, got)
+			}
+
+			if err = store.Delete(ctx, "s1"); err != nil {
+				t.Fatal(err)
+			}
+			if msgs, _ := store.Load(ctx, "s1"); len(msgs) != 0 {
+				t.Errorf("Expected empty session after delete, got %d messages", len(msgs))
+			}
+		})
+	}
+
+	if _, err = fileStore.Load(context.Background(), "../escape"); err == nil {
+		t.Error("Expected error for invalid session id")
+	}
+}
+
+func TestSession_Branching(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemorySessionStore()
+	options := SessionOptions{Model: "mock", Provider: &summaryProvider{}, Store: store}
+	session := NewSession("s1", options)
+
+	send := func(chunks <-chan StreamChunk, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = CollectStream(chunks); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	send(session.Send(ctx, "q1"))
+	send(session.Send(ctx, "q2"))
+	q2 := session.Children(session.Children(session.Children("")[0].ID)[0].ID)[0]
+
+	// 重新生成最后一条回复
+	send(session.Regenerate(ctx))
+	expected := []string{"user:q1", "assistant:summary 1", "user:q2", "assistant:summary 3"}
+	if got := roles(session.Messages()); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+	if n := len(session.Children(q2.ID)); n != 2 {
+		t.Errorf("Expected 2 replies to q2, got %d", n)
+	}
+
+	// 编辑 q2，创建新分支
+	send(session.Edit(ctx, q2.ID, "q2 edited"))
+	expected = []string{"user:q1", "assistant:summary 1", "user:q2 edited", "assistant:summary 4"}
+	if got := roles(session.Messages()); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+
+	// 切回原分支，停在最新的回复上
+	if err := session.SwitchBranch(ctx, q2.ID); err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{"user:q1", "assistant:summary 1", "user:q2", "assistant:summary 3"}
+	if got := roles(session.Messages()); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+
+	// 从第一条回复处分叉
+	if err := session.Fork(ctx, q2.ParentID); err != nil {
+		t.Fatal(err)
+	}
+	send(session.Send(ctx, "q3"))
+	expected = []string{"user:q1", "assistant:summary 1", "user:q3", "assistant:summary 5"}
+	if got := roles(session.Messages()); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+
+	// 重新加载后树结构保持不变
+	loaded, err := LoadSession(ctx, "s1", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := roles(loaded.Messages()); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %q after reload, got %q", expected, got)
+	}
+	if n := len(loaded.Children(q2.ParentID)); n != 3 {
+		t.Errorf("Expected 3 branches after first reply, got %d", n)
+	}
+}
+
+// flakyProvider 前 failures 次调用返回错误，之后发送 n 个块
+type flakyProvider struct {
+	failures int
+	n        int
+}
+
+func (p *flakyProvider) IsAvailable() bool {
+	return true
+}
+
+func (p *flakyProvider) StreamChat(ctx context.Context, req *ChatRequest) (<-chan StreamChunk, error) {
+	if p.failures > 0 {
+		p.failures--
+		return nil, errors.New("upstream unavailable")
+	}
+	chunks := make(chan StreamChunk)
+	go func() {
+		defer close(chunks)
+		for i := 0; i < p.n; i++ {
+			select {
+			case <-ctx.Done():
+				chunks <- StreamChunk{Error: ctx.Err()}
+				return
+			case chunks <- StreamChunk{Content: "x"}:
+			}
+		}
+	}()
+	return chunks, nil
+}
+
+// failingStore 追加总是失败
+type failingStore struct {
+	MemorySessionStore
+}
+
+func (s *failingStore) Append(ctx context.Context, sessionID string, msgs ...SessionMessage) error {
+	return errors.New("disk full")
+}
+
+func TestSession_SendFailures(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemorySessionStore()
+	session := NewSession("s1", SessionOptions{Provider: &flakyProvider{failures: 1, n: 1}, Store: store})
+
+	// 上游失败时不留下孤立的用户消息，重试不会重复
+	if _, err := session.Send(ctx, "q1"); err == nil {
+		t.Fatal("Expected upstream error")
+	}
+	if msgs, _ := store.Load(ctx, "s1"); len(msgs) != 0 || session.Head() != "" || len(session.Children("")) != 0 {
+		t.Fatalf("Expected no persisted messages, got %d", len(msgs))
+	}
+	chunks, err := session.Send(ctx, "q1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = CollectStream(chunks); err != nil {
+		t.Fatal(err)
+	}
+	if msgs, _ := store.Load(ctx, "s1"); len(msgs) != 2 {
+		t.Errorf("Expected question and reply, got %d messages", len(msgs))
+	}
+
+	// 持久化失败时回滚
+	broken := NewSession("s2", SessionOptions{Provider: &flakyProvider{n: 1}, Store: &failingStore{}})
+	if _, err = broken.Send(ctx, "q1"); err == nil || broken.Head() != "" {
+		t.Errorf("Expected rollback after store error, got %v head %q", err, broken.Head())
+	}
+}
+
+func TestSession_AbandonedStream(t *testing.T) {
+	session := NewSession("s1", SessionOptions{Provider: &flakyProvider{n: 1000}, Store: NewMemorySessionStore()})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	chunks, err := session.Send(ctx, "q1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-chunks
+	// 调用方取消后不再读取，会话仍应结束生成
+	cancel()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		chunks, err = session.Send(context.Background(), "q2")
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, ErrSessionBusy) || time.Now().After(deadline) {
+			t.Fatalf("Expected session to become idle, got %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, err = CollectStream(chunks); err != nil {
+		t.Fatal(err)
+	}
+	if got := session.Messages(); len(got) != 3 || got[0].Content != "q1" || got[1].Content != "q2" {
+		t.Errorf("Expected the abandoned reply to be discarded, got %q", roles(got))
+	}
+}
+
+func TestSession_BranchPersistence(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemorySessionStore()
+	provider := &flakyProvider{n: 1}
+	options := SessionOptions{Provider: provider, Store: store}
+	session := NewSession("s1", options)
+
+	for _, question := range []string{"q1", "q2"} {
+		chunks, err := session.Send(ctx, question)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = CollectStream(chunks); err != nil {
+			t.Fatal(err)
+		}
+	}
+	q1 := session.Children("")[0]
+	q2 := session.Children(session.Children(q1.ID)[0].ID)[0]
+
+	reload := func() *Session {
+		t.Helper()
+		loaded, err := LoadSession(ctx, "s1", options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return loaded
+	}
+
+	// 分叉后重新加载仍停在分叉点
+	if err := session.Fork(ctx, q1.ID); err != nil {
+		t.Fatal(err)
+	}
+	if loaded := reload(); loaded.Head() != q1.ID || len(loaded.Messages()) != 1 {
+		t.Errorf("Expected head %s after reload, got %s", q1.ID, loaded.Head())
+	}
+
+	head := session.Children(q2.ID)[0].ID
+	if err := session.SwitchBranch(ctx, q2.ID); err != nil {
+		t.Fatal(err)
+	}
+	if loaded := reload(); loaded.Head() != head {
+		t.Errorf("Expected head %s after reload, got %s", head, loaded.Head())
+	}
+
+	// 编辑失败时不留下孤立消息，分支保持不变
+	before, _ := store.Load(ctx, "s1")
+	provider.failures = 1
+	if _, err := session.Edit(ctx, q2.ID, "q2 edited"); err == nil {
+		t.Fatal("Expected upstream error")
+	}
+	if after, _ := store.Load(ctx, "s1"); len(after) != len(before) || session.Head() != head || len(session.Children(q2.ParentID)) != 1 {
+		t.Errorf("Expected failed edit to leave no trace, got %d records", len(after))
+	}
+}
+
+// blockingProvider 的 StreamChat 在 release 关闭前阻塞，模拟耗时的压缩或连接
+type blockingProvider struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (p *blockingProvider) IsAvailable() bool {
+	return true
+}
+
+func (p *blockingProvider) StreamChat(ctx context.Context, req *ChatRequest) (<-chan StreamChunk, error) {
+	close(p.started)
+	<-p.release
+	chunks := make(chan StreamChunk, 1)
+	chunks <- StreamChunk{Content: "a1", FinishReason: "stop"}
+	close(chunks)
+	return chunks, nil
+}
+
+func TestSession_UnlockedUpstream(t *testing.T) {
+	ctx := context.Background()
+	provider := &blockingProvider{started: make(chan struct{}), release: make(chan struct{})}
+	session := NewSession("s1", SessionOptions{Provider: provider, Store: NewMemorySessionStore()})
+
+	type result struct {
+		chunks <-chan StreamChunk
+		err    error
+	}
+	sent := make(chan result, 1)
+	go func() {
+		chunks, err := session.Send(ctx, "q1")
+		sent <- result{chunks, err}
+	}()
+	<-provider.started
+
+	// 上游阻塞期间读取不被阻塞，并发发送返回忙碌
+	read := make(chan []ChatMessage, 1)
+	go func() { read <- session.Messages() }()
+	select {
+	case msgs := <-read:
+		if len(msgs) != 0 {
+			t.Errorf("Expected the prompt to be uncommitted, got %q", roles(msgs))
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Messages blocked while the upstream was opening")
+	}
+	if _, err := session.Send(ctx, "q2"); !errors.Is(err, ErrSessionBusy) {
+		t.Errorf("Expected ErrSessionBusy, got %v", err)
+	}
+
+	close(provider.release)
+	r := <-sent
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	if _, err := CollectStream(r.chunks); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"user:q1", "assistant:a1"}
+	if got := roles(session.Messages()); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
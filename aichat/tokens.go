@@ -0,0 +1,30 @@
+package aichat
+
+// 消息格式开销，参考 OpenAI cookbook 的计算方式
+const (
+	tokensPerMessage = 3 // 每条消息的 <|start|>{role}\n ... <|end|>
+	tokensPerReply   = 3 // 回复以 <|start|>assistant<|message|> 开头
+)
+
+// TokenCounter 文本 token 计数器，tokenizer.Encoding 即实现了该接口
+type TokenCounter interface {
+	Count(text string) int
+}
+
+// CountMessageTokens 统计单条消息占用的 token 数
+func CountMessageTokens(counter TokenCounter, msg ChatMessage) int {
+	n := tokensPerMessage + counter.Count(msg.Role) + counter.Count(msg.Content)
+	for _, call := range msg.ToolCalls {
+		n += counter.Count(call.Function.Name) + counter.Count(call.Function.Arguments)
+	}
+	return n
+}
+
+// CountTokens 统计请求提示部分占用的 token 数，包含每条消息的格式开销
+func CountTokens(counter TokenCounter, req *ChatRequest) int {
+	n := tokensPerReply
+	for _, msg := range req.Messages {
+		n += CountMessageTokens(counter, msg)
+	}
+	return n
+}
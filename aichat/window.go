@@ -0,0 +1,184 @@
+package aichat
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrContextOverflow = errors.New("messages exceed context window")
+
+// messageGroup 裁剪的最小单位，同组消息要么全部保留要么全部丢弃
+type messageGroup struct {
+	messages []ChatMessage
+	tokens   int
+}
+
+// TrimStrategy 历史消息裁剪策略
+type TrimStrategy interface {
+	// Trim 裁剪消息使总 token 数不超过 budget，count 用于统计单条消息
+	Trim(msgs []ChatMessage, budget int, count func(ChatMessage) int) []ChatMessage
+}
+
+// ContextWindow 上下文窗口管理，在发送前裁剪 ChatRequest.Messages
+type ContextWindow struct {
+	Counter   TokenCounter
+	MaxTokens int          // 模型上下文长度
+	Reserve   int          // 为回复预留的 token 数，为 0 时使用 ChatRequest.MaxTokens
+	Strategy  TrimStrategy // 为空时使用 DropOldestTurns
+}
+
+// Fit 裁剪请求消息以适应上下文窗口，裁剪后仍超出时返回 ErrContextOverflow
+func (w *ContextWindow) Fit(req *ChatRequest) error {
+	reserve := w.Reserve
+	if reserve == 0 {
+		reserve = req.MaxTokens
+	}
+	budget := w.MaxTokens - reserve - tokensPerReply
+
+	count := func(msg ChatMessage) int {
+		return CountMessageTokens(w.Counter, msg)
+	}
+	if totalTokens(req.Messages, count) <= budget {
+		return nil
+	}
+
+	strategy := w.Strategy
+	if strategy == nil {
+		strategy = DropOldestTurns{}
+	}
+	req.Messages = strategy.Trim(req.Messages, budget, count)
+
+	if n := totalTokens(req.Messages, count); n > budget {
+		return fmt.Errorf("%w: %d > %d tokens", ErrContextOverflow, n, budget)
+	}
+	return nil
+}
+
+// KeepLastN 保留全部 system 消息和最近 N 条消息（至少保留最后一组），仍超出预算时继续丢弃最早的消息
+type KeepLastN struct {
+	N int
+}
+
+func (s KeepLastN) Trim(msgs []ChatMessage, budget int, count func(ChatMessage) int) []ChatMessage {
+	groups := toolGroups(msgs, count)
+
+	// 从尾部向前累计，直到覆盖 N 条非 system 消息
+	kept, start := 0, len(groups)
+	for start > 0 && kept < s.N {
+		start--
+		if !isSystemGroup(groups[start]) {
+			kept += len(groups[start].messages)
+		}
+	}
+	if start == len(groups) && len(groups) > 0 {
+		// 至少保留最后一组作为当前提问
+		start = len(groups) - 1
+	}
+
+	var selected []messageGroup
+	for i, g := range groups {
+		if i >= start || isSystemGroup(g) {
+			selected = append(selected, g)
+		}
+	}
+	return flatten(dropOldest(selected, budget, isSystemGroup))
+}
+
+// DropOldestTurns 按轮次（一条 user 消息及其后的回复）从最早开始丢弃，保留 system 消息
+type DropOldestTurns struct{}
+
+func (DropOldestTurns) Trim(msgs []ChatMessage, budget int, count func(ChatMessage) int) []ChatMessage {
+	return flatten(dropOldest(turnGroups(msgs, count), budget, isSystemGroup))
+}
+
+// KeepPinned 保留包含固定消息的整轮对话及 system 消息，其余按时间从早到晚丢弃
+type KeepPinned struct {
+	Pinned func(msg ChatMessage) bool
+}
+
+func (s KeepPinned) Trim(msgs []ChatMessage, budget int, count func(ChatMessage) int) []ChatMessage {
+	pinned := func(g messageGroup) bool {
+		if isSystemGroup(g) {
+			return true
+		}
+		for _, msg := range g.messages {
+			if s.Pinned != nil && s.Pinned(msg) {
+				return true
+			}
+		}
+		return false
+	}
+	return flatten(dropOldest(turnGroups(msgs, count), budget, pinned))
+}
+
+// dropOldest 从最早的组开始丢弃，跳过 keep 的组，最后一组（当前提问）总是保留
+func dropOldest(groups []messageGroup, budget int, keep func(messageGroup) bool) []messageGroup {
+	total := 0
+	for _, g := range groups {
+		total += g.tokens
+	}
+
+	result := make([]messageGroup, 0, len(groups))
+	for i, g := range groups {
+		if total > budget && i < len(groups)-1 && !keep(g) {
+			total -= g.tokens
+			continue
+		}
+		result = append(result, g)
+	}
+	return result
+}
+
+// toolGroups 按工具调用分组：带 ToolCalls 的 assistant 消息与其后的 tool 结果为一组
+func toolGroups(msgs []ChatMessage, count func(ChatMessage) int) []messageGroup {
+	var groups []messageGroup
+	for _, msg := range msgs {
+		n := len(groups)
+		if msg.Role == "tool" && n > 0 && hasToolCalls(groups[n-1]) {
+			groups[n-1].messages = append(groups[n-1].messages, msg)
+			groups[n-1].tokens += count(msg)
+			continue
+		}
+		groups = append(groups, messageGroup{messages: []ChatMessage{msg}, tokens: count(msg)})
+	}
+	return groups
+}
+
+// turnGroups 按对话轮次分组：system 消息单独成组，user 消息开启新的一组
+func turnGroups(msgs []ChatMessage, count func(ChatMessage) int) []messageGroup {
+	var groups []messageGroup
+	for _, msg := range msgs {
+		n := len(groups)
+		if msg.Role != "system" && msg.Role != "user" && n > 0 && !isSystemGroup(groups[n-1]) {
+			groups[n-1].messages = append(groups[n-1].messages, msg)
+			groups[n-1].tokens += count(msg)
+			continue
+		}
+		groups = append(groups, messageGroup{messages: []ChatMessage{msg}, tokens: count(msg)})
+	}
+	return groups
+}
+
+func isSystemGroup(g messageGroup) bool {
+	return len(g.messages) == 1 && g.messages[0].Role == "system"
+}
+
+func hasToolCalls(g messageGroup) bool {
+	return g.messages[0].Role == "assistant" && len(g.messages[0].ToolCalls) > 0
+}
+
+func flatten(groups []messageGroup) []ChatMessage {
+	var msgs []ChatMessage
+	for _, g := range groups {
+		msgs = append(msgs, g.messages...)
+	}
+	return msgs
+}
+
+func totalTokens(msgs []ChatMessage, count func(ChatMessage) int) int {
+	n := 0
+	for _, msg := range msgs {
+		n += count(msg)
+	}
+	return n
+}
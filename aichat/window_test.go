@@ -0,0 +1,113 @@
+package aichat
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// wordCounter 按空格分词计数，便于构造测试数据
+type wordCounter struct{}
+
+func (wordCounter) Count(text string) int {
+	return len(strings.Fields(text))
+}
+
+func roles(msgs []ChatMessage) []string {
+	var result []string
+	for _, msg := range msgs {
+		result = append(result, msg.Role+":"+msg.Content)
+	}
+	return result
+}
+
+func TestCountTokens(t *testing.T) {
+	req := &ChatRequest{Messages: []ChatMessage{
+		{Role: "system", Content: "be brief"},
+		{Role: "user", Content: "hi there"},
+	}}
+	// 3 (reply) + 2 * (3 + 1 role + 2 content)
+	if n := CountTokens(wordCounter{}, req); n != 15 {
+		t.Errorf("Expected 15 tokens, got %d", n)
+	}
+}
+
+func TestContextWindow_Fit(t *testing.T) {
+	history := []ChatMessage{
+		{Role: "system", Content: "sys"},
+		{Role: "user", Content: "q1 a b c d"},
+		{Role: "assistant", Content: "a1 a b c d"},
+		{Role: "user", Content: "q2 a b c d"},
+		{Role: "assistant", Content: "call", ToolCalls: []ToolCall{{ID: "1", Type: "function"}}},
+		{Role: "tool", Content: "result a b c", ToolCallID: "1"},
+		{Role: "assistant", Content: "a2 a b c d"},
+		{Role: "user", Content: "q3"},
+	}
+
+	tests := []struct {
+		name      string
+		strategy  TrimStrategy
+		maxTokens int
+		expected  []string
+		overflow  bool
+	}{
+		{
+			name:      "FitsWithoutTrim",
+			maxTokens: 1000,
+			expected:  roles(history),
+		},
+		{
+			name:      "DropOldestTurns",
+			strategy:  DropOldestTurns{},
+			maxTokens: 40,
+			expected:  []string{"system:sys", "user:q3"},
+		},
+		{
+			name:      "DropOldestTurnsKeepsRecentTurn",
+			maxTokens: 60,
+			expected:  []string{"system:sys", "user:q2 a b c d", "assistant:call", "tool:result a b c", "assistant:a2 a b c d", "user:q3"},
+		},
+		{
+			name:      "KeepLastNKeepsToolPair",
+			strategy:  KeepLastN{N: 3},
+			maxTokens: 60,
+			expected:  []string{"system:sys", "assistant:call", "tool:result a b c", "assistant:a2 a b c d", "user:q3"},
+		},
+		{
+			name: "KeepPinned",
+			strategy: KeepPinned{Pinned: func(msg ChatMessage) bool {
+				return strings.HasPrefix(msg.Content, "q1")
+			}},
+			maxTokens: 40,
+			expected:  []string{"system:sys", "user:q1 a b c d", "assistant:a1 a b c d", "user:q3"},
+		},
+		{
+			name:      "KeepLastNZeroKeepsQuestion",
+			strategy:  KeepLastN{},
+			maxTokens: 40,
+			expected:  []string{"system:sys", "user:q3"},
+		},
+		{
+			name:      "Overflow",
+			maxTokens: 10,
+			expected:  []string{"system:sys", "user:q3"},
+			overflow:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &ChatRequest{Messages: append([]ChatMessage(nil), history...)}
+			w := &ContextWindow{Counter: wordCounter{}, MaxTokens: tt.maxTokens, Strategy: tt.strategy}
+
+			err := w.Fit(req)
+			if tt.overflow != errors.Is(err, ErrContextOverflow) {
+				t.Errorf("Expected overflow %v, got %v", tt.overflow, err)
+			}
+			if got := roles(req.Messages); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,359 @@
+package batch
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"chatlib/aichat"
+)
+
+const chatCompletionsURL = "/v1/chat/completions"
+
+// Request 批处理输入行，格式与 OpenAI Batch API 一致
+type Request struct {
+	CustomID string             `json:"custom_id"`
+	Method   string             `json:"method,omitempty"`
+	URL      string             `json:"url,omitempty"`
+	Body     aichat.ChatRequest `json:"body"`
+}
+
+// Result 批处理输出行，成功时 Response 非空，失败时 Error 非空
+type Result struct {
+	ID       string    `json:"id"`
+	CustomID string    `json:"custom_id"`
+	Response *Response `json:"response"`
+	Error    *Error    `json:"error"`
+}
+
+// Response 单个请求的响应
+type Response struct {
+	StatusCode int        `json:"status_code"`
+	RequestID  string     `json:"request_id"`
+	Body       Completion `json:"body"`
+}
+
+// Error 单个请求的错误
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Completion chat.completion 响应体
+type Completion struct {
+	ID      string   `json:"id"`
+	Object  string   `json:"object"`
+	Created int64    `json:"created"`
+	Model   string   `json:"model"`
+	Choices []Choice `json:"choices"`
+}
+
+// Choice 响应中的候选回复
+type Choice struct {
+	Index        int                `json:"index"`
+	Message      aichat.ChatMessage `json:"message"`
+	FinishReason string             `json:"finish_reason"`
+}
+
+// Summary 批处理统计
+type Summary struct {
+	Total     int
+	Skipped   int
+	Succeeded int
+	Failed    int
+}
+
+// Runner 并发执行批处理请求
+type Runner struct {
+	Factory   aichat.ModelFactory
+	Workers   int     // 并发数，默认 4
+	RateLimit float64 // 每秒最多发起的请求数，0 表示不限制
+}
+
+// Run 从 in 逐行读取请求并执行，成功结果写入 results，失败写入 errs；
+// done 中的 custom_id 视为已完成并跳过。输入格式错误时中止并返回错误
+func (r *Runner) Run(ctx context.Context, in io.Reader, results, errs io.Writer, done map[string]bool) (Summary, error) {
+	workers := r.Workers
+	if workers <= 0 {
+		workers = 4
+	}
+
+	var throttle <-chan time.Time
+	if r.RateLimit > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / r.RateLimit))
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+
+	var (
+		mu      sync.Mutex
+		summary Summary
+		wg      sync.WaitGroup
+	)
+	resultEnc, errEnc := json.NewEncoder(results), json.NewEncoder(errs)
+	write := func(res Result) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if res.Error != nil {
+			summary.Failed++
+			return errEnc.Encode(res)
+		}
+		summary.Succeeded++
+		return resultEnc.Encode(res)
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	jobs := make(chan Request)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for req := range jobs {
+				res := r.execute(ctx, req)
+				// 运行被取消时未完成的请求不记录，恢复运行时会重新执行
+				if res.Error != nil && ctx.Err() != nil {
+					continue
+				}
+				if err := write(res); err != nil {
+					cancel(err)
+				}
+			}
+		}()
+	}
+
+	total, skipped, err := r.dispatch(ctx, in, jobs, done, throttle)
+	close(jobs)
+	wg.Wait()
+
+	summary.Total, summary.Skipped = total, skipped
+
+	if err == nil {
+		err = context.Cause(ctx)
+	}
+	return summary, err
+}
+
+// dispatch 解析输入并分发请求，直到输入结束或 ctx 结束，返回请求总数和跳过数
+func (r *Runner) dispatch(ctx context.Context, in io.Reader, jobs chan<- Request, done map[string]bool,
+	throttle <-chan time.Time) (total, skipped int, err error) {
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var req Request
+		if err = json.Unmarshal([]byte(text), &req); err != nil {
+			return total, skipped, fmt.Errorf("line %d: invalid JSON: %v", line, err)
+		}
+		if req.CustomID == "" {
+			return total, skipped, fmt.Errorf("line %d: missing custom_id", line)
+		}
+		if seen[req.CustomID] {
+			return total, skipped, fmt.Errorf("line %d: duplicate custom_id %q", line, req.CustomID)
+		}
+		seen[req.CustomID] = true
+
+		total++
+		if done[req.CustomID] {
+			skipped++
+			continue
+		}
+
+		if throttle != nil {
+			select {
+			case <-ctx.Done():
+				return total, skipped, nil
+			case <-throttle:
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return total, skipped, nil
+		case jobs <- req:
+		}
+	}
+	return total, skipped, scanner.Err()
+}
+
+// execute 执行单个请求，错误以 Result.Error 返回
+func (r *Runner) execute(ctx context.Context, req Request) Result {
+	res := Result{ID: newID("batch_req_"), CustomID: req.CustomID}
+	fail := func(code, msg string) Result {
+		res.Error = &Error{Code: code, Message: msg}
+		return res
+	}
+
+	if req.Method != "" && req.Method != http.MethodPost {
+		return fail("invalid_request", "unsupported method "+req.Method)
+	}
+	if req.URL != "" && req.URL != chatCompletionsURL {
+		return fail("invalid_request", "unsupported url "+req.URL)
+	}
+	if req.Body.Model == "" || len(req.Body.Messages) == 0 {
+		return fail("invalid_request", "model and messages are required")
+	}
+
+	provider, err := r.Factory.GetProvider(req.Body.Model)
+	if err != nil {
+		return fail("model_not_found", err.Error())
+	}
+
+	body := req.Body
+	body.Stream = true
+	chunks, err := provider.StreamChat(ctx, &body)
+	if err != nil {
+		return fail("upstream_error", err.Error())
+	}
+
+	var content strings.Builder
+	finishReason := "stop"
+	for chunk := range chunks {
+		if chunk.Error != nil {
+			err = chunk.Error
+			continue
+		}
+		content.WriteString(chunk.Content)
+		if chunk.FinishReason != "" {
+			finishReason = chunk.FinishReason
+		}
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		return fail("upstream_error", err.Error())
+	}
+
+	res.Response = &Response{
+		StatusCode: http.StatusOK,
+		RequestID:  newID("req_"),
+		Body: Completion{
+			ID:      newID("chatcmpl-"),
+			Object:  "chat.completion",
+			Created: time.Now().Unix(),
+			Model:   body.Model,
+			Choices: []Choice{{
+				Message:      aichat.ChatMessage{Role: "assistant", Content: content.String()},
+				FinishReason: finishReason,
+			}},
+		},
+	}
+	return res
+}
+
+// Completed 读取已有的结果文件，返回其中成功完成的 custom_id；
+// 中断时写了一半的行会被忽略
+func Completed(r io.Reader) (map[string]bool, error) {
+	done := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var res Result
+		if json.Unmarshal(scanner.Bytes(), &res) != nil {
+			continue
+		}
+		if res.CustomID != "" && res.Error == nil && res.Response != nil {
+			done[res.CustomID] = true
+		}
+	}
+	return done, scanner.Err()
+}
+
+// OpenOutput 以追加方式打开输出文件，返回其中已完成的 custom_id；
+// 若文件末尾是写了一半的行，先补上换行，避免与新结果粘连
+func OpenOutput(path string) (*os.File, map[string]bool, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	done, err := Completed(f)
+	if err == nil {
+		err = terminateLine(f)
+	}
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, done, nil
+}
+
+// CompactErrors 整理错误文件：每个 custom_id 只保留最后一条错误，done 中已成功的请求不再保留。
+// 失败的请求在恢复运行时会重新执行，整理后错误文件不会出现重复记录
+func CompactErrors(path string, done map[string]bool) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var (
+		order []string
+		last  = make(map[string][]byte)
+	)
+	for _, line := range strings.Split(string(data), "\n") {
+		var res Result
+		if json.Unmarshal([]byte(line), &res) != nil || res.CustomID == "" {
+			continue
+		}
+		if _, ok := last[res.CustomID]; !ok {
+			order = append(order, res.CustomID)
+		}
+		last[res.CustomID] = []byte(line)
+	}
+
+	var out strings.Builder
+	for _, id := range order {
+		if !done[id] {
+			out.Write(last[id])
+			out.WriteByte('\n')
+		}
+	}
+
+	// 先写临时文件再替换，避免中途失败损坏原文件
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, []byte(out.String()), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func terminateLine(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil || info.Size() == 0 {
+		return err
+	}
+	last := make([]byte, 1)
+	if _, err = f.ReadAt(last, info.Size()-1); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	if last[0] == '\n' {
+		return nil
+	}
+	_, err = f.Write([]byte("\n"))
+	return err
+}
+
+func newID(prefix string) string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return prefix + hex.EncodeToString(b)
+}
@@ -0,0 +1,242 @@
+package batch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"chatlib/aichat"
+)
+
+// echoProvider 回显最后一条消息，记录最大并发数
+type echoProvider struct {
+	mu       sync.Mutex
+	calls    []string
+	active   atomic.Int32
+	maxConc  atomic.Int32
+	failWith string
+}
+
+func (p *echoProvider) IsAvailable() bool {
+	return true
+}
+
+func (p *echoProvider) StreamChat(ctx context.Context, req *aichat.ChatRequest) (<-chan aichat.StreamChunk, error) {
+	content := req.Messages[len(req.Messages)-1].Content
+	p.mu.Lock()
+	p.calls = append(p.calls, content)
+	p.mu.Unlock()
+
+	n := p.active.Add(1)
+	for {
+		m := p.maxConc.Load()
+		if n <= m || p.maxConc.CompareAndSwap(m, n) {
+			break
+		}
+	}
+
+	chunks := make(chan aichat.StreamChunk, 2)
+	go func() {
+		defer close(chunks)
+		defer p.active.Add(-1)
+		time.Sleep(10 * time.Millisecond)
+		if content == p.failWith {
+			chunks <- aichat.StreamChunk{Error: errors.New("upstream failed")}
+			return
+		}
+		chunks <- aichat.StreamChunk{Content: "echo " + content}
+		chunks <- aichat.StreamChunk{FinishReason: "stop"}
+	}()
+	return chunks, nil
+}
+
+func input(ids ...string) string {
+	var sb strings.Builder
+	for _, id := range ids {
+		line, _ := json.Marshal(Request{
+			CustomID: id,
+			Method:   "POST",
+			URL:      "/v1/chat/completions",
+			Body:     aichat.ChatRequest{Model: "echo", Messages: []aichat.ChatMessage{{Role: "user", Content: id}}},
+		})
+		sb.Write(line)
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+func decode(t *testing.T, data string) []Result {
+	t.Helper()
+	var results []Result
+	for _, line := range strings.Split(strings.TrimSpace(data), "\n") {
+		if line == "" {
+			continue
+		}
+		var res Result
+		if err := json.Unmarshal([]byte(line), &res); err != nil {
+			t.Fatalf("invalid output line %q: %v", line, err)
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+func newRunner(provider *echoProvider) *Runner {
+	factory := aichat.NewDefaultModelFactory()
+	factory.RegisterProvider("echo", provider)
+	return &Runner{Factory: factory, Workers: 3}
+}
+
+func TestRunner_Run(t *testing.T) {
+	provider := &echoProvider{failWith: "r4"}
+	runner := newRunner(provider)
+
+	in := input("r1", "r2", "r3", "r4", "r5", "r6") +
+		`{"custom_id":"r7","body":{"model":"missing","messages":[{"role":"user","content":"x"}]}}` + "\n"
+	var results, errs bytes.Buffer
+	summary, err := runner.Run(context.Background(), strings.NewReader(in), &results, &errs, map[string]bool{"r2": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Summary{Total: 7, Skipped: 1, Succeeded: 4, Failed: 2}
+	if summary != want {
+		t.Errorf("Expected %+v, got %+v", want, summary)
+	}
+	if max := provider.maxConc.Load(); max > 3 {
+		t.Errorf("Expected at most 3 concurrent requests, got %d", max)
+	}
+
+	for _, res := range decode(t, results.String()) {
+		if res.Error != nil || res.Response.StatusCode != 200 || res.Response.Body.Choices[0].Message.Content != "echo "+res.CustomID {
+			t.Errorf("Unexpected result %+v", res)
+		}
+		if !strings.HasPrefix(res.ID, "batch_req_") {
+			t.Errorf("Unexpected result id %q", res.ID)
+		}
+	}
+
+	codes := make(map[string]string)
+	for _, res := range decode(t, errs.String()) {
+		if res.Response != nil {
+			t.Errorf("Expected null response in error line %+v", res)
+		}
+		codes[res.CustomID] = res.Error.Code
+	}
+	if codes["r4"] != "upstream_error" || codes["r7"] != "model_not_found" {
+		t.Errorf("Unexpected error codes %v", codes)
+	}
+}
+
+func TestRunner_InvalidInput(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{"InvalidJSON", input("a") + "{\n", "line 2: invalid JSON"},
+		{"MissingID", `{"body":{}}`, "line 1: missing custom_id"},
+		{"Duplicate", input("a", "a"), `line 2: duplicate custom_id "a"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var results, errs bytes.Buffer
+			_, err := newRunner(&echoProvider{}).Run(context.Background(), strings.NewReader(tt.input), &results, &errs, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Expected error %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestRunner_RateLimit(t *testing.T) {
+	runner := newRunner(&echoProvider{})
+	runner.RateLimit = 50
+
+	start := time.Now()
+	var results, errs bytes.Buffer
+	if _, err := runner.Run(context.Background(), strings.NewReader(input("a", "b", "c", "d", "e")), &results, &errs, nil); err != nil {
+		t.Fatal(err)
+	}
+	// 5 个请求间隔 20ms
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("Expected rate limit to space requests, took %v", elapsed)
+	}
+}
+
+func TestOpenOutput_Resume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.jsonl")
+	provider := &echoProvider{}
+	runner := newRunner(provider)
+
+	var errs bytes.Buffer
+	f, done, err := OpenOutput(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = runner.Run(context.Background(), strings.NewReader(input("a", "b")), f, &errs, done); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	// 模拟中断时写了一半的行
+	f, _ = os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	_, _ = f.WriteString(`{"id":"batch_req_x","custom_id":"c","resp`)
+	f.Close()
+
+	f, done, err = OpenOutput(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != 2 || !done["a"] || !done["b"] {
+		t.Errorf("Expected a and b completed, got %v", done)
+	}
+	summary, err := runner.Run(context.Background(), strings.NewReader(input("a", "b", "c", "d")), f, &errs, done)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Skipped != 2 || summary.Succeeded != 2 || len(provider.calls) != 4 {
+		t.Errorf("Unexpected resume summary %+v, calls %v", summary, provider.calls)
+	}
+
+	data, _ := os.ReadFile(path)
+	f2, final, _ := OpenOutput(path)
+	f2.Close()
+	if len(final) != 4 || strings.Count(string(data), "\n") != 5 {
+		t.Errorf("Expected 4 completed ids after resume, got %v\n%s", final, data)
+	}
+}
+
+func TestCompactErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "errors.jsonl")
+	record := func(id, msg string) string {
+		data, _ := json.Marshal(Result{ID: "batch_req_" + id, CustomID: id, Error: &Error{Code: "upstream_error", Message: msg}})
+		return string(data) + "\n"
+	}
+	// 第一次运行 a、b 失败，恢复后 a 成功、b 再次失败，最后一行写了一半
+	content := record("a", "first") + record("b", "first") + record("b", "second") + `{"id":"batch_req_c","cus`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CompactErrors(path, map[string]bool{"a": true}); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != record("b", "second") {
+		t.Errorf("Expected only the latest error for b, got %s", data)
+	}
+
+	if err := CompactErrors(filepath.Join(t.TempDir(), "missing.jsonl"), nil); err != nil {
+		t.Errorf("Expected missing file to be ignored, got %v", err)
+	}
+}
@@ -0,0 +1,357 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"chatlib/aichat"
+	"chatlib/stream"
+)
+
+// 生成任务状态
+const (
+	GenerationRunning   = "running"
+	GenerationCompleted = "completed"
+	GenerationFailed    = "failed"
+	GenerationCancelled = "cancelled"
+)
+
+// GenerationOptions 生成任务配置
+type GenerationOptions struct {
+	TTL        time.Duration // 结束后保留多久，默认 10 分钟
+	Timeout    time.Duration // 单个生成的最长时间，0 表示不限制
+	BufferSize int           // 每个生成在内存中保留的事件数，默认 8192
+	// Events 为每个生成创建事件存储，默认为保留 BufferSize 个事件的 stream.ReplayBuffer
+	Events func(generationID string) EventStore
+}
+
+// EventStore 保存一个生成的 SSE 事件并按 Last-Event-ID 向客户端补发，
+// 可替换为外部存储以突破内存限制或跨进程重连，*stream.ReplayBuffer 是其内存实现
+type EventStore interface {
+	Append(msg stream.SSEMessage) stream.SSEMessage // 分配 ID 并保存
+	Close()                                         // 生成结束，读取方读完后断开
+	LastEventID() string                            // 最后一条事件的 ID
+	Evicted(lastID string) bool                     // lastID 之后是否已有事件被丢弃
+	http.Handler                                    // 以 SSE 补发 Last-Event-ID 之后的事件并持续输出
+}
+
+// 默认的事件存储超出 BufferSize 后丢弃最早的事件。此时带 Last-Event-ID 从更早位置重连的
+// 客户端收到 410，不带 Last-Event-ID 的新连接从当前位置接收之后的事件，
+// 完整内容仍可通过 GET /v1/generations/{id} 获取
+
+// Generation 在后台运行的一次生成，生命周期与发起它的 HTTP 请求无关
+type Generation struct {
+	ID      string
+	Model   string
+	Created time.Time
+
+	events EventStore
+	cancel context.CancelFunc
+
+	mu           sync.Mutex
+	status       string
+	content      strings.Builder
+	finishReason string
+	err          string
+}
+
+// generationInfo 生成任务的查询结果
+type generationInfo struct {
+	ID           string `json:"id"`
+	Object       string `json:"object"`
+	Model        string `json:"model"`
+	Created      int64  `json:"created"`
+	Status       string `json:"status"`
+	Content      string `json:"content"`
+	FinishReason string `json:"finish_reason,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// Status 返回当前状态
+func (g *Generation) Status() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.status
+}
+
+func (g *Generation) info() generationInfo {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return generationInfo{
+		ID:           g.ID,
+		Object:       "generation",
+		Model:        g.Model,
+		Created:      g.Created.Unix(),
+		Status:       g.status,
+		Content:      g.content.String(),
+		FinishReason: g.finishReason,
+		Error:        g.err,
+	}
+}
+
+// GenerationManager 管理后台生成任务。客户端通过生成 ID 随时连接或断开
+// SSE 流，断开不会取消生成；重连时按 Last-Event-ID 补发错过的块
+type GenerationManager struct {
+	factory aichat.ModelFactory
+	options GenerationOptions
+
+	mu          sync.Mutex
+	generations map[string]*Generation
+}
+
+func NewGenerationManager(factory aichat.ModelFactory, options GenerationOptions) *GenerationManager {
+	if options.TTL <= 0 {
+		options.TTL = 10 * time.Minute
+	}
+	if options.BufferSize <= 0 {
+		options.BufferSize = 8192
+	}
+	if options.Events == nil {
+		size := options.BufferSize
+		options.Events = func(string) EventStore {
+			return stream.NewReplayBuffer(size)
+		}
+	}
+	return &GenerationManager{
+		factory:     factory,
+		options:     options,
+		generations: make(map[string]*Generation),
+	}
+}
+
+// Register 在网关上注册生成任务相关的路由：
+//
+//	POST /v1/generations              创建生成，返回生成 ID
+//	GET  /v1/generations/{id}         查询状态和已生成的内容
+//	GET  /v1/generations/{id}/stream  以 SSE 连接生成，支持 Last-Event-ID
+//	POST /v1/generations/{id}/cancel  取消生成
+func (m *GenerationManager) Register(s *Server) {
+	s.Handle("POST /v1/generations", http.HandlerFunc(m.handleStart))
+	s.Handle("GET /v1/generations/{id}", http.HandlerFunc(m.handleGet))
+	s.Handle("GET /v1/generations/{id}/stream", http.HandlerFunc(m.handleStream))
+	s.Handle("POST /v1/generations/{id}/cancel", http.HandlerFunc(m.handleCancel))
+}
+
+// Start 在后台启动一次生成
+func (m *GenerationManager) Start(req *aichat.ChatRequest) (*Generation, error) {
+	provider, err := m.factory.GetProvider(req.Model)
+	if err != nil {
+		return nil, err
+	}
+	return m.start(provider, req)
+}
+
+func (m *GenerationManager) start(provider aichat.ModelProvider, req *aichat.ChatRequest) (*Generation, error) {
+	// 生成不继承请求的上下文，客户端断开不影响生成
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+	if m.options.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), m.options.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+
+	body := *req
+	body.Stream = true
+	chunks, err := provider.StreamChat(ctx, &body)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	id := newGenerationID()
+	g := &Generation{
+		ID:      id,
+		Model:   req.Model,
+		Created: time.Now(),
+		events:  m.options.Events(id),
+		cancel:  cancel,
+		status:  GenerationRunning,
+	}
+	m.mu.Lock()
+	m.generations[g.ID] = g
+	m.mu.Unlock()
+
+	go m.run(ctx, g, chunks)
+	return g, nil
+}
+
+// Get 按 ID 查找生成
+func (m *GenerationManager) Get(id string) (*Generation, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	g, ok := m.generations[id]
+	return g, ok
+}
+
+// Cancel 取消正在运行的生成，已结束的生成保持原状态，生成不存在时返回 false
+func (m *GenerationManager) Cancel(id string) bool {
+	g, ok := m.Get(id)
+	if !ok {
+		return false
+	}
+	g.finish(GenerationCancelled, "", "generation cancelled")
+	g.cancel()
+	return true
+}
+
+// Close 取消所有生成
+func (m *GenerationManager) Close() {
+	m.mu.Lock()
+	ids := make([]string, 0, len(m.generations))
+	for id := range m.generations {
+		ids = append(ids, id)
+	}
+	m.mu.Unlock()
+
+	for _, id := range ids {
+		m.Cancel(id)
+	}
+}
+
+// run 读取上游的块，以 chat.completion.chunk 事件写入事件存储，结束后按 TTL 清理
+func (m *GenerationManager) run(ctx context.Context, g *Generation, chunks <-chan aichat.StreamChunk) {
+	defer drain(chunks)
+	defer g.cancel()
+
+	base := completionChunk{ID: g.ID, Object: "chat.completion.chunk", Created: g.Created.Unix(), Model: g.Model}
+	emit := func(d delta, finishReason *string) {
+		c := base
+		c.Choices = []chunkChoice{{Delta: d, FinishReason: finishReason}}
+		data, _ := json.Marshal(c)
+		g.events.Append(stream.SSEMessage{Data: string(data)})
+	}
+
+	emit(delta{Role: "assistant"}, nil)
+	finishReason := "stop"
+	var streamErr error
+	for chunk := range chunks {
+		if chunk.Error != nil {
+			streamErr = chunk.Error
+			break
+		}
+		if chunk.Content != "" {
+			g.mu.Lock()
+			g.content.WriteString(chunk.Content)
+			g.mu.Unlock()
+			emit(delta{Content: chunk.Content}, nil)
+		}
+		if chunk.FinishReason != "" {
+			finishReason = chunk.FinishReason
+		}
+	}
+	if streamErr == nil {
+		// 部分提供者在上下文结束时直接关闭通道
+		streamErr = ctx.Err()
+	}
+
+	if streamErr != nil {
+		g.finish(GenerationFailed, "", streamErr.Error())
+	} else {
+		g.finish(GenerationCompleted, finishReason, "")
+	}
+	g.mu.Lock()
+	status, errMsg := g.status, g.err
+	g.mu.Unlock()
+
+	if status == GenerationCompleted {
+		emit(delta{}, &finishReason)
+		g.events.Append(stream.SSEMessage{Data: doneMessage})
+	} else {
+		data, _ := json.Marshal(errorResponse{Error: errorBody{Message: errMsg, Type: "upstream_error"}})
+		g.events.Append(stream.SSEMessage{Event: "error", Data: string(data)})
+	}
+	g.events.Close()
+
+	time.AfterFunc(m.options.TTL, func() {
+		m.mu.Lock()
+		delete(m.generations, g.ID)
+		m.mu.Unlock()
+	})
+}
+
+// finish 在生成仍在运行时设置结束状态，先到者生效
+func (g *Generation) finish(status, finishReason, errMsg string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.status == GenerationRunning {
+		g.status, g.finishReason, g.err = status, finishReason, errMsg
+	}
+}
+
+func newGenerationID() string {
+	return newRandomID("gen_")
+}
+
+func (m *GenerationManager) handleStart(w http.ResponseWriter, r *http.Request) {
+	var req aichat.ChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body: "+err.Error())
+		return
+	}
+	if req.Model == "" || len(req.Messages) == 0 {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "model and messages are required")
+		return
+	}
+
+	provider, err := m.factory.GetProvider(req.Model)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "invalid_request_error", err.Error())
+		return
+	}
+	g, err := m.start(provider, &req)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "upstream_error", err.Error())
+		return
+	}
+
+	w.Header().Set("Location", "/v1/generations/"+g.ID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(g.info())
+}
+
+func (m *GenerationManager) handleGet(w http.ResponseWriter, r *http.Request) {
+	if g, ok := m.lookup(w, r); ok {
+		writeJSON(w, g.info())
+	}
+}
+
+func (m *GenerationManager) handleStream(w http.ResponseWriter, r *http.Request) {
+	g, ok := m.lookup(w, r)
+	if !ok {
+		return
+	}
+	lastID := r.Header.Get("Last-Event-ID")
+	if g.events.Evicted(lastID) {
+		if lastID != "" {
+			writeError(w, http.StatusGone, "invalid_request_error", "earlier events of this generation were evicted, fetch /v1/generations/"+g.ID+" for the full content")
+			return
+		}
+		// 新连接无法从头回放，从当前位置开始接收之后的事件
+		r = r.Clone(r.Context())
+		r.Header.Set("Last-Event-ID", g.events.LastEventID())
+	}
+	g.events.ServeHTTP(w, r)
+}
+
+func (m *GenerationManager) handleCancel(w http.ResponseWriter, r *http.Request) {
+	if g, ok := m.lookup(w, r); ok {
+		m.Cancel(g.ID)
+		writeJSON(w, g.info())
+	}
+}
+
+func (m *GenerationManager) lookup(w http.ResponseWriter, r *http.Request) (*Generation, bool) {
+	g, ok := m.Get(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "invalid_request_error", "generation not found")
+	}
+	return g, ok
+}
@@ -0,0 +1,280 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"chatlib/aichat"
+	"chatlib/stream"
+)
+
+// gatedProvider 每收到一个信号输出一个块，上下文取消时结束
+type gatedProvider struct {
+	words []string
+	next  chan struct{}
+}
+
+func (p *gatedProvider) IsAvailable() bool {
+	return true
+}
+
+func (p *gatedProvider) StreamChat(ctx context.Context, req *aichat.ChatRequest) (<-chan aichat.StreamChunk, error) {
+	chunks := make(chan aichat.StreamChunk)
+	go func() {
+		defer close(chunks)
+		for _, word := range p.words {
+			select {
+			case <-ctx.Done():
+				chunks <- aichat.StreamChunk{Error: ctx.Err()}
+				return
+			case <-p.next:
+			}
+			chunks <- aichat.StreamChunk{Content: word}
+		}
+		chunks <- aichat.StreamChunk{FinishReason: "stop"}
+	}()
+	return chunks, nil
+}
+
+func newGenerationServer(options GenerationOptions) (*Server, *GenerationManager, *gatedProvider) {
+	gated := &gatedProvider{words: []string{"Hel", "lo"}, next: make(chan struct{})}
+	factory := aichat.NewDefaultModelFactory()
+	factory.RegisterProvider("gated", gated)
+
+	s := NewServer(factory)
+	m := NewGenerationManager(factory, options)
+	m.Register(s)
+	return s, m, gated
+}
+
+func startGeneration(t *testing.T, s *Server) generationInfo {
+	t.Helper()
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("POST", "/v1/generations", strings.NewReader(`{"model":"gated","messages":[{"role":"user","content":"hi"}]}`)))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	var info generationInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	}
+	if w.Header().Get("Location") != "/v1/generations/"+info.ID || info.Status != GenerationRunning {
+		t.Fatalf("Unexpected start response %+v", info)
+	}
+	return info
+}
+
+func getGeneration(s *Server, id string) (int, generationInfo) {
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/v1/generations/"+id, nil))
+	var info generationInfo
+	_ = json.Unmarshal(w.Body.Bytes(), &info)
+	return w.Code, info
+}
+
+func waitStatus(t *testing.T, s *Server, id, status string) generationInfo {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		_, info := getGeneration(s, id)
+		if info.Status == status {
+			return info
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected status %s, got %+v", status, info)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func streamEvents(s *Server, id, lastEventID string) (int, []string) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/v1/generations/"+id+"/stream", nil)
+	if lastEventID != "" {
+		r.Header.Set("Last-Event-ID", lastEventID)
+	}
+	s.ServeHTTP(w, r)
+	return w.Code, strings.Split(strings.TrimSuffix(w.Body.String(), "\n\n"), "\n\n")
+}
+
+func TestGenerationManager_DetachedFromClient(t *testing.T) {
+	s, _, gated := newGenerationServer(GenerationOptions{})
+	info := startGeneration(t, s)
+
+	// 客户端连接后断开，生成继续进行
+	ctx, cancel := context.WithCancel(context.Background())
+	r := httptest.NewRequest("GET", "/v1/generations/"+info.ID+"/stream", nil).WithContext(ctx)
+	done := make(chan struct{})
+	go func() {
+		s.ServeHTTP(httptest.NewRecorder(), r)
+		close(done)
+	}()
+	gated.next <- struct{}{}
+	cancel()
+	<-done
+
+	gated.next <- struct{}{}
+	final := waitStatus(t, s, info.ID, GenerationCompleted)
+	if final.Content != "Hello" || final.FinishReason != "stop" {
+		t.Errorf("Unexpected final state %+v", final)
+	}
+
+	// 重新连接得到完整的事件序列
+	_, events := streamEvents(s, info.ID, "")
+	if len(events) != 5 || !strings.HasPrefix(events[0], "id: 1\n") || events[4] != "id: 5\ndata: [DONE]" {
+		t.Fatalf("Unexpected events %q", events)
+	}
+	for i, event := range events[1:3] {
+		var chunk completionChunk
+		if err := json.Unmarshal([]byte(strings.SplitN(event, "data: ", 2)[1]), &chunk); err != nil {
+			t.Fatal(err)
+		}
+		if chunk.ID != info.ID || chunk.Choices[0].Delta.Content != []string{"Hel", "lo"}[i] {
+			t.Errorf("Unexpected chunk %+v", chunk)
+		}
+	}
+
+	// 按 Last-Event-ID 只补发错过的事件，全部收到后返回 204
+	if _, events = streamEvents(s, info.ID, "3"); len(events) != 2 || !strings.HasPrefix(events[0], "id: 4\n") {
+		t.Errorf("Expected events 4 and 5, got %q", events)
+	}
+	if code, _ := streamEvents(s, info.ID, "5"); code != http.StatusNoContent {
+		t.Errorf("Expected 204 once caught up, got %d", code)
+	}
+}
+
+func TestGenerationManager_Cancel(t *testing.T) {
+	s, _, gated := newGenerationServer(GenerationOptions{})
+	info := startGeneration(t, s)
+	gated.next <- struct{}{}
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("POST", "/v1/generations/"+info.ID+"/cancel", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+
+	_, events := streamEvents(s, info.ID, "")
+	if last := events[len(events)-1]; !strings.Contains(last, "event: error\n") {
+		t.Errorf("Expected stream to end with an error event, got %q", last)
+	}
+	if _, final := getGeneration(s, info.ID); final.Status != GenerationCancelled || final.Content != "Hel" || final.Error == "" {
+		t.Errorf("Unexpected cancelled state %+v", final)
+	}
+}
+
+func TestGenerationManager_CancelAfterCompletion(t *testing.T) {
+	s, m, gated := newGenerationServer(GenerationOptions{})
+	info := startGeneration(t, s)
+	close(gated.next)
+	waitStatus(t, s, info.ID, GenerationCompleted)
+
+	if !m.Cancel(info.ID) {
+		t.Fatal("Expected generation to exist")
+	}
+	if _, final := getGeneration(s, info.ID); final.Status != GenerationCompleted || final.Error != "" {
+		t.Errorf("Expected completed generation to stay completed, got %+v", final)
+	}
+}
+
+func TestGenerationManager_Evicted(t *testing.T) {
+	s, _, gated := newGenerationServer(GenerationOptions{BufferSize: 2})
+	info := startGeneration(t, s)
+	close(gated.next)
+	waitStatus(t, s, info.ID, GenerationCompleted)
+
+	// 5 个事件只保留最后 2 个，从被丢弃的位置重连时明确失败
+	if code, _ := streamEvents(s, info.ID, "1"); code != http.StatusGone {
+		t.Errorf("Expected 410 when the resume point was evicted, got %d", code)
+	}
+	if code, events := streamEvents(s, info.ID, "3"); code != http.StatusOK || len(events) != 2 {
+		t.Errorf("Expected the buffered events 4 and 5, got %d %q", code, events)
+	}
+	// 新连接从当前位置加入，生成已结束所以没有之后的事件
+	if code, _ := streamEvents(s, info.ID, ""); code != http.StatusNoContent {
+		t.Errorf("Expected 204 when joining a finished generation, got %d", code)
+	}
+}
+
+// attachStore 在客户端连接时发出通知
+type attachStore struct {
+	*stream.ReplayBuffer
+	attached chan string
+}
+
+func (s *attachStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.attached <- r.Header.Get("Last-Event-ID")
+	s.ReplayBuffer.ServeHTTP(w, r)
+}
+
+func TestGenerationManager_JoinLive(t *testing.T) {
+	// 缓冲能容纳加入后的 3 个事件，但开头的事件已被丢弃
+	store := &attachStore{ReplayBuffer: stream.NewReplayBuffer(3), attached: make(chan string, 1)}
+	s, _, gated := newGenerationServer(GenerationOptions{Events: func(string) EventStore { return store }})
+	gated.words = []string{"a", "b", "c", "d"}
+	info := startGeneration(t, s)
+	for range 3 {
+		gated.next <- struct{}{}
+	}
+	for store.LastEventID() != "4" {
+		time.Sleep(time.Millisecond)
+	}
+
+	// 开头已被丢弃，不带 Last-Event-ID 的连接从当前位置接收之后的事件
+	type result struct {
+		code   int
+		events []string
+	}
+	streamed := make(chan result, 1)
+	go func() {
+		code, events := streamEvents(s, info.ID, "")
+		streamed <- result{code, events}
+	}()
+	if lastID := <-store.attached; lastID != "4" {
+		t.Errorf("Expected to join after event 4, got %q", lastID)
+	}
+	close(gated.next)
+
+	r := <-streamed
+	if r.code != http.StatusOK || len(r.events) != 3 || !strings.HasPrefix(r.events[0], "id: 5\n") || !strings.Contains(r.events[0], `"content":"d"`) {
+		t.Errorf("Expected the events after joining, got %d %q", r.code, r.events)
+	}
+}
+
+func TestGenerationManager_Timeout(t *testing.T) {
+	s, _, _ := newGenerationServer(GenerationOptions{Timeout: 10 * time.Millisecond})
+	info := startGeneration(t, s)
+	if final := waitStatus(t, s, info.ID, GenerationFailed); !strings.Contains(final.Error, "deadline exceeded") {
+		t.Errorf("Unexpected timeout state %+v", final)
+	}
+}
+
+func TestGenerationManager_TTL(t *testing.T) {
+	s, m, gated := newGenerationServer(GenerationOptions{TTL: 10 * time.Millisecond})
+	info := startGeneration(t, s)
+	close(gated.next)
+	waitStatus(t, s, info.ID, GenerationCompleted)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, ok := m.Get(info.ID); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected generation to expire")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	for _, path := range []string{"/v1/generations/" + info.ID, "/v1/generations/" + info.ID + "/stream"} {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s: expected 404, got %d", path, w.Code)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"chatlib/aichat"
+	"chatlib/stream"
+)
+
+const doneMessage = "[DONE]"
+
+// 网关路由，SetFlushPolicy 按此设置
+const (
+	RouteChatCompletions = "POST /v1/chat/completions"
+	RouteChatWebSocket   = "GET /v1/chat/ws"
+)
+
+// Server OpenAI 兼容的网关，按模型名将请求路由到对应的 ModelProvider
+type Server struct {
+	factory aichat.ModelFactory
+	mux     *http.ServeMux
+	flush   map[string]stream.FlushPolicy // 按路由的合并策略
+}
+
+func NewServer(factory aichat.ModelFactory) *Server {
+	s := &Server{
+		factory: factory,
+		mux:     http.NewServeMux(),
+		flush:   make(map[string]stream.FlushPolicy),
+	}
+	s.mux.HandleFunc(RouteChatCompletions, s.handleChatCompletions)
+	s.mux.HandleFunc("GET /v1/models", s.handleModels)
+	s.mux.HandleFunc(RouteChatWebSocket, s.handleWebSocket)
+	return s
+}
+
+// SetFlushPolicy 设置某个路由合并内容块的策略，合并后的每个事件立即发送，默认每个块单独发送。需在开始服务前调用
+func (s *Server) SetFlushPolicy(route string, policy stream.FlushPolicy) {
+	s.flush[route] = policy
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Handle 注册额外的路由
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+func (s *Server) handleModels(w http.ResponseWriter, r *http.Request) {
+	names := s.factory.ListAvailableModels()
+	sort.Strings(names)
+
+	list := modelList{Object: "list", Data: make([]model, 0, len(names))}
+	for _, name := range names {
+		list.Data = append(list.Data, model{ID: name, Object: "model", OwnedBy: "chatlib"})
+	}
+	writeJSON(w, list)
+}
+
+func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	var req aichat.ChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body: "+err.Error())
+		return
+	}
+	if req.Model == "" || len(req.Messages) == 0 {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "model and messages are required")
+		return
+	}
+
+	provider, err := s.factory.GetProvider(req.Model)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "invalid_request_error", err.Error())
+		return
+	}
+
+	// 流式请求默认使用 SSE，Accept 显式请求时使用 NDJSON，JSON 数组只能通过 ?format=json 获得
+	var streamer stream.Streamer
+	if req.Stream {
+		if streamer, err = stream.NegotiateStream(r, nil); err != nil {
+			writeError(w, http.StatusNotAcceptable, "invalid_request_error", "stream output supports text/event-stream or application/x-ndjson, use ?format=json for a JSON array")
+			return
+		}
+	}
+
+	clientStream := req.Stream
+	req.Stream = true
+	chunks, err := provider.StreamChat(r.Context(), &req)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "upstream_error", err.Error())
+		return
+	}
+
+	if clientStream {
+		// 刷新策略只用于合并内容块，合并后的每个事件立即发送，避免间隔叠加
+		chunks = aichat.CoalesceChunks(r.Context(), chunks, s.flush[r.Pattern])
+		s.streamCompletion(r.Context(), w, streamer, req.Model, chunks)
+	} else {
+		s.writeCompletion(w, req.Model, chunks)
+	}
+}
+
+// streamCompletion 将流式响应转换为 OpenAI 的 chat.completion.chunk 事件，SSE 以 [DONE] 结束
+func (s *Server) streamCompletion(ctx context.Context, w http.ResponseWriter, streamer stream.Streamer, modelName string, chunks <-chan aichat.StreamChunk) {
+	_, isSSE := streamer.(*stream.SSEStream)
+	events := stream.Generate(ctx, func(emit func(any) bool) error {
+		defer drain(chunks)
+
+		base := completionChunk{ID: newCompletionID(), Object: "chat.completion.chunk", Created: time.Now().Unix(), Model: modelName}
+		newChunk := func(d delta, finishReason *string) completionChunk {
+			c := base
+			c.Choices = []chunkChoice{{Delta: d, FinishReason: finishReason}}
+			return c
+		}
+
+		if !emit(newChunk(delta{Role: "assistant"}, nil)) {
+			return nil
+		}
+		finishReason := "stop"
+		for chunk := range chunks {
+			if chunk.Error != nil {
+				emit(errorResponse{Error: errorBody{Message: chunk.Error.Error(), Type: "upstream_error"}})
+				return nil
+			}
+			if chunk.Content != "" && !emit(newChunk(delta{Content: chunk.Content}, nil)) {
+				return nil
+			}
+			if chunk.FinishReason != "" {
+				finishReason = chunk.FinishReason
+			}
+		}
+		if emit(newChunk(delta{}, &finishReason)) && isSSE {
+			emit(doneMessage)
+		}
+		return nil
+	})
+
+	// 响应头发送后出错只能中断连接，错误无需再处理
+	_ = stream.WriteStream(w, streamer, stream.Encode(events, encodeEvent))
+}
+
+// encodeEvent 将事件编码为 JSON，[DONE] 原样输出
+func encodeEvent(v any) ([]byte, error) {
+	if v == doneMessage {
+		return []byte(doneMessage), nil
+	}
+	return json.Marshal(v)
+}
+
+// writeCompletion 汇总流式响应，以 chat.completion 对象一次性返回
+func (s *Server) writeCompletion(w http.ResponseWriter, modelName string, chunks <-chan aichat.StreamChunk) {
+	defer drain(chunks)
+
+	var content strings.Builder
+	finishReason := "stop"
+	for chunk := range chunks {
+		if chunk.Error != nil {
+			writeError(w, http.StatusBadGateway, "upstream_error", chunk.Error.Error())
+			return
+		}
+		content.WriteString(chunk.Content)
+		if chunk.FinishReason != "" {
+			finishReason = chunk.FinishReason
+		}
+	}
+
+	writeJSON(w, completion{
+		ID:      newCompletionID(),
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   modelName,
+		Choices: []completionChoice{{
+			Message:      message{Role: "assistant", Content: content.String()},
+			FinishReason: finishReason,
+		}},
+	})
+}
+
+// drain 丢弃剩余的块，避免提前返回时上游 goroutine 阻塞
+func drain(chunks <-chan aichat.StreamChunk) {
+	go func() {
+		for range chunks {
+		}
+	}()
+}
@@ -0,0 +1,279 @@
+package gateway
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"chatlib/aichat"
+	"chatlib/stream"
+)
+
+// fakeProvider 依次返回预设的块
+type fakeProvider struct {
+	chunks []aichat.StreamChunk
+}
+
+func (p *fakeProvider) IsAvailable() bool {
+	return true
+}
+
+func (p *fakeProvider) StreamChat(ctx context.Context, req *aichat.ChatRequest) (<-chan aichat.StreamChunk, error) {
+	chunks := make(chan aichat.StreamChunk, len(p.chunks))
+	for _, c := range p.chunks {
+		chunks <- c
+	}
+	close(chunks)
+	return chunks, nil
+}
+
+func newTestServer() *Server {
+	factory := aichat.NewDefaultModelFactory()
+	factory.RegisterProvider("fake", &fakeProvider{chunks: []aichat.StreamChunk{
+		{Content: "Hel"}, {Content: "lo"}, {FinishReason: "stop"},
+	}})
+	factory.RegisterProvider("broken", &fakeProvider{chunks: []aichat.StreamChunk{
+		{Content: "Hi"}, {Error: errors.New("upstream failed")},
+	}})
+	return NewServer(factory)
+}
+
+func post(s *Server, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body)))
+	return w
+}
+
+func TestServer_StreamingCompletion(t *testing.T) {
+	w := post(newTestServer(), `{"model":"fake","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Expected text/event-stream, got %q", ct)
+	}
+
+	events := strings.Split(strings.TrimSuffix(w.Body.String(), "\n\n"), "\n\n")
+	if len(events) != 5 || events[4] != "data: [DONE]" {
+		t.Fatalf("Expected 5 events ending with [DONE], got %q", events)
+	}
+
+	var content strings.Builder
+	for i, event := range events[:4] {
+		var chunk completionChunk
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(event, "data: ")), &chunk); err != nil {
+			t.Fatal(err)
+		}
+		if chunk.Object != "chat.completion.chunk" || chunk.Model != "fake" || !strings.HasPrefix(chunk.ID, "chatcmpl-") {
+			t.Errorf("Unexpected chunk %+v", chunk)
+		}
+		choice := chunk.Choices[0]
+		if i == 0 && choice.Delta.Role != "assistant" {
+			t.Errorf("Expected first chunk to carry the role, got %+v", choice)
+		}
+		if i == 3 && (choice.FinishReason == nil || *choice.FinishReason != "stop") {
+			t.Errorf("Expected last chunk to carry finish_reason, got %+v", choice)
+		}
+		if i < 3 && choice.FinishReason != nil {
+			t.Errorf("Expected finish_reason null, got %q", *choice.FinishReason)
+		}
+		content.WriteString(choice.Delta.Content)
+	}
+	if content.String() != "Hello" {
+		t.Errorf("Expected content %q, got %q", "Hello", content.String())
+	}
+
+	// finish_reason 为 null 时必须显式输出
+	if !strings.Contains(events[1], `"finish_reason":null`) {
+		t.Errorf("Expected explicit null finish_reason, got %s", events[1])
+	}
+}
+
+func TestServer_StreamingFormats(t *testing.T) {
+	s := newTestServer()
+	body := `{"model":"fake","stream":true,"messages":[{"role":"user","content":"hi"}]}`
+	request := func(target, accept string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", target, strings.NewReader(body))
+		r.Header.Set("Accept", accept)
+		s.ServeHTTP(w, r)
+		return w
+	}
+
+	// OpenAI SDK 默认发送 Accept: application/json，仍然得到 SSE
+	for _, accept := range []string{"application/json", "*/*", ""} {
+		w := request("/v1/chat/completions", accept)
+		if w.Header().Get("Content-Type") != "text/event-stream" || !strings.HasSuffix(w.Body.String(), "data: [DONE]\n\n") {
+			t.Errorf("Accept %q: expected SSE, got %q %q", accept, w.Header().Get("Content-Type"), w.Body.String())
+		}
+	}
+
+	// NDJSON 每行一个块，没有 [DONE]
+	w := request("/v1/chat/completions", "application/x-ndjson")
+	lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+	if w.Header().Get("Content-Type") != "application/x-ndjson" || len(lines) != 4 {
+		t.Fatalf("Expected 4 NDJSON lines, got %q", w.Body.String())
+	}
+	for _, line := range lines {
+		var chunk completionChunk
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil || chunk.Object != "chat.completion.chunk" {
+			t.Errorf("Unexpected line %s", line)
+		}
+	}
+
+	w = request("/v1/chat/completions?format=json", "application/json")
+	var chunks []completionChunk
+	if err := json.Unmarshal(w.Body.Bytes(), &chunks); err != nil || len(chunks) != 4 {
+		t.Fatalf("Expected JSON array of 4 chunks, got %s", w.Body.String())
+	}
+	if w.Header().Get("Content-Length") == "" {
+		t.Error("Expected buffered response with Content-Length")
+	}
+
+	if w = request("/v1/chat/completions", "text/html"); w.Code != http.StatusNotAcceptable {
+		t.Errorf("Expected status 406, got %d", w.Code)
+	}
+}
+
+// flushRecorder 记录每次 Flush 时已写出的内容
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushes []string
+}
+
+func (w *flushRecorder) Flush() {
+	w.flushes = append(w.flushes, w.Body.String())
+}
+
+func TestServer_FlushPolicy(t *testing.T) {
+	s := newTestServer()
+	s.SetFlushPolicy(RouteChatCompletions, stream.FlushPolicy{MaxBytes: 5})
+
+	w := post(s, `{"model":"fake","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	events := strings.Split(strings.TrimSuffix(w.Body.String(), "\n\n"), "\n\n")
+	if len(events) != 4 || !strings.Contains(events[1], `"content":"Hello"`) {
+		t.Errorf("Expected the content chunks to be merged, got %q", events)
+	}
+
+	// 策略只作用于设置它的路由
+	other := newTestServer()
+	other.SetFlushPolicy(RouteChatWebSocket, stream.FlushPolicy{MaxBytes: 5})
+	w = post(other, `{"model":"fake","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	if events = strings.Split(strings.TrimSuffix(w.Body.String(), "\n\n"), "\n\n"); len(events) != 5 {
+		t.Errorf("Expected chunks of another route's policy to stay separate, got %q", events)
+	}
+}
+
+func TestServer_FlushSentences(t *testing.T) {
+	factory := aichat.NewDefaultModelFactory()
+	factory.RegisterProvider("fake", &fakeProvider{chunks: []aichat.StreamChunk{
+		{Content: "Hel"}, {Content: "lo."}, {Content: " Bye"}, {FinishReason: "stop"},
+	}})
+	s := NewServer(factory)
+	s.SetFlushPolicy(RouteChatCompletions, stream.FlushPolicy{Interval: time.Hour, Boundary: stream.SentenceBoundary})
+
+	w := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	s.ServeHTTP(w, httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model":"fake","stream":true,"messages":[{"role":"user","content":"hi"}]}`)))
+
+	// 内容按句子合并，其余内容等到流结束；每个事件单独刷新
+	if len(w.flushes) != 5 || !strings.HasSuffix(w.flushes[1], `"content":"Hello."},"finish_reason":null}]}`+"\n\n") ||
+		!strings.HasSuffix(w.flushes[2], `"content":" Bye"},"finish_reason":null}]}`+"\n\n") {
+		t.Fatalf("Expected the sentence and the rest as separate flushed events, got %q", w.flushes)
+	}
+	if w.flushes[4] != w.Body.String() || !strings.HasSuffix(w.Body.String(), "data: [DONE]\n\n") {
+		t.Errorf("Unexpected final flush %q", w.flushes[4])
+	}
+}
+
+func TestServer_FlushInterval(t *testing.T) {
+	gated := &gatedProvider{words: []string{"Hi", "there"}, next: make(chan struct{})}
+	factory := aichat.NewDefaultModelFactory()
+	factory.RegisterProvider("gated", gated)
+	s := NewServer(factory)
+	interval := 200 * time.Millisecond
+	s.SetFlushPolicy(RouteChatCompletions, stream.FlushPolicy{Interval: interval})
+
+	server := httptest.NewServer(s)
+	defer server.Close()
+	start := time.Now()
+	resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json", strings.NewReader(`{"model":"gated","stream":true,"messages":[{"role":"user","content":"hi"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	// 上游输出一个块后保持连接，内容应在一个间隔左右到达，而不是合并和 SSE 各等一个间隔
+	gated.next <- struct{}{}
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(line, `"content":"Hi"`) {
+			break
+		}
+	}
+	if elapsed := time.Since(start); elapsed > interval*3/2 {
+		t.Errorf("Expected content within about one interval, got %v", elapsed)
+	}
+	close(gated.next)
+	_, _ = io.Copy(io.Discard, reader)
+}
+
+func TestServer_Completion(t *testing.T) {
+	s := newTestServer()
+
+	w := post(s, `{"model":"fake","messages":[{"role":"user","content":"hi"}]}`)
+	var resp completion
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Object != "chat.completion" || resp.Choices[0].Message.Content != "Hello" || resp.Choices[0].FinishReason != "stop" {
+		t.Errorf("Unexpected completion %+v", resp)
+	}
+	if strings.Contains(w.Body.String(), `"usage"`) {
+		t.Errorf("Expected unknown usage to be omitted, got %s", w.Body.String())
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"InvalidJSON", `{`, http.StatusBadRequest},
+		{"MissingMessages", `{"model":"fake"}`, http.StatusBadRequest},
+		{"UnknownModel", `{"model":"nope","messages":[{"role":"user","content":"hi"}]}`, http.StatusNotFound},
+		{"UpstreamError", `{"model":"broken","messages":[{"role":"user","content":"hi"}]}`, http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := post(s, tt.body)
+			if w.Code != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, w.Code)
+			}
+			var resp errorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Error.Message == "" {
+				t.Errorf("Expected OpenAI error body, got %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestServer_Models(t *testing.T) {
+	w := httptest.NewRecorder()
+	newTestServer().ServeHTTP(w, httptest.NewRequest("GET", "/v1/models", nil))
+
+	var list modelList
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if list.Object != "list" || len(list.Data) != 2 || list.Data[0].ID != "broken" || list.Data[1].ID != "fake" {
+		t.Errorf("Unexpected model list %+v", list)
+	}
+}
@@ -0,0 +1,118 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"chatlib/aichat"
+	"chatlib/stream"
+)
+
+// continuePrompt 客户端发送 continue 时追加的用户消息
+const continuePrompt = "请从上次中断的地方继续。"
+
+// handleWebSocket 通过 WebSocket 进行多轮流式对话：
+//
+//	{"type":"chat","data":<ChatRequest>}  开始一次生成
+//	{"type":"cancel"}                     取消当前生成
+//	{"type":"continue"}                   在上次（可能被取消的）回复基础上继续生成
+//
+// 每个块以 chat.completion.chunk JSON 文本消息发送，上游中途出错时以 {"type":"error"} 结束该轮
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := stream.UpgradeWebSocket(w, r)
+	if err != nil {
+		return
+	}
+
+	policy := s.flush[r.Pattern]
+
+	// 同一时间只有一轮生成，以下状态只在 Serve 的 goroutine 中读写
+	var (
+		last  *aichat.ChatRequest
+		reply strings.Builder
+	)
+
+	// 回复只记录实际发出的块，取消后被丢弃的块不计入
+	ws := &stream.WebSocketStream{Sent: func(data []byte) {
+		var c completionChunk
+		if json.Unmarshal(data, &c) == nil && len(c.Choices) > 0 {
+			reply.WriteString(c.Choices[0].Delta.Content)
+		}
+	}}
+	turn := func(ctx context.Context, msg stream.ControlMessage) (*stream.Stream[[]byte], error) {
+		var req aichat.ChatRequest
+		switch msg.Type {
+		case "chat":
+			if err := json.Unmarshal(msg.Data, &req); err != nil {
+				return nil, errors.New("invalid chat request: " + err.Error())
+			}
+			if req.Model == "" || len(req.Messages) == 0 {
+				return nil, errors.New("model and messages are required")
+			}
+		case stream.ControlContinue:
+			if last == nil {
+				return nil, errors.New("nothing to continue")
+			}
+			req = *last
+			req.Messages = append(append([]aichat.ChatMessage(nil), last.Messages...),
+				aichat.ChatMessage{Role: "assistant", Content: reply.String()},
+				aichat.ChatMessage{Role: "user", Content: continuePrompt},
+			)
+		default:
+			return nil, errors.New("unknown message type " + msg.Type)
+		}
+
+		provider, err := s.factory.GetProvider(req.Model)
+		if err != nil {
+			return nil, err
+		}
+		req.Stream = true
+		chunks, err := provider.StreamChat(ctx, &req)
+		if err != nil {
+			return nil, err
+		}
+
+		last = &req
+		reply.Reset()
+		return s.wsChunks(ctx, req.Model, aichat.CoalesceChunks(ctx, chunks, policy)), nil
+	}
+	_ = ws.Serve(r.Context(), conn, turn)
+}
+
+// wsChunks 将流式响应转换为 chat.completion.chunk JSON
+func (s *Server) wsChunks(ctx context.Context, modelName string, chunks <-chan aichat.StreamChunk) *stream.Stream[[]byte] {
+	return stream.Generate(ctx, func(emit func([]byte) bool) error {
+		defer drain(chunks)
+
+		base := completionChunk{ID: newCompletionID(), Object: "chat.completion.chunk", Created: time.Now().Unix(), Model: modelName}
+		send := func(c completionChunk) bool {
+			b, _ := json.Marshal(c)
+			return emit(b)
+		}
+
+		finishReason := "stop"
+		for chunk := range chunks {
+			if chunk.Error != nil {
+				return chunk.Error
+			}
+			if chunk.Content != "" {
+				c := base
+				c.Choices = []chunkChoice{{Delta: delta{Content: chunk.Content}}}
+				if !send(c) {
+					return nil
+				}
+			}
+			if chunk.FinishReason != "" {
+				finishReason = chunk.FinishReason
+			}
+		}
+		c := base
+		c.Choices = []chunkChoice{{Delta: delta{}, FinishReason: &finishReason}}
+		send(c)
+		return nil
+	})
+}
@@ -0,0 +1,193 @@
+package gateway
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"chatlib/aichat"
+	"chatlib/stream"
+)
+
+// dialChatWS 建立 WebSocket 连接，返回发送和接收函数
+func dialChatWS(t *testing.T, s *Server) (func(string), func() string) {
+	t.Helper()
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	_, _ = io.WriteString(conn, "GET /v1/chat/ws HTTP/1.1\r\nHost: test\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n")
+	br := bufio.NewReader(conn)
+	if resp, err := http.ReadResponse(br, nil); err != nil || resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Handshake failed: %v", err)
+	}
+
+	send := func(msg string) {
+		// 客户端帧必须带掩码，这里使用全零掩码
+		frame := []byte{0x80 | stream.OpText, 0x80 | 126}
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(msg)))
+		frame = append(frame, 0, 0, 0, 0)
+		_, _ = conn.Write(append(frame, msg...))
+	}
+	recv := func() string {
+		header := make([]byte, 2)
+		_, _ = io.ReadFull(br, header)
+		n := int(header[1] & 0x7f)
+		if n == 126 {
+			_, _ = io.ReadFull(br, header)
+			n = int(binary.BigEndian.Uint16(header))
+		}
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(br, payload); err != nil {
+			t.Fatal(err)
+		}
+		return string(payload)
+	}
+	return send, recv
+}
+
+// recordingProvider 记录收到的请求
+type recordingProvider struct {
+	fakeProvider
+	requests []*aichat.ChatRequest
+}
+
+func (p *recordingProvider) StreamChat(ctx context.Context, req *aichat.ChatRequest) (<-chan aichat.StreamChunk, error) {
+	p.requests = append(p.requests, req)
+	return p.fakeProvider.StreamChat(ctx, req)
+}
+
+func TestServer_WebSocket(t *testing.T) {
+	provider := &recordingProvider{fakeProvider: fakeProvider{chunks: []aichat.StreamChunk{{Content: "Hel"}, {Content: "lo"}}}}
+	factory := aichat.NewDefaultModelFactory()
+	factory.RegisterProvider("fake", provider)
+	send, recv := dialChatWS(t, NewServer(factory))
+
+	send(`{"type":"continue"}`)
+	if got := recv(); got != `{"type":"error","error":"nothing to continue"}` {
+		t.Fatalf("Unexpected reply %s", got)
+	}
+
+	send(`{"type":"chat","data":{"model":"fake","messages":[{"role":"user","content":"hi"}]}}`)
+	var content strings.Builder
+	for {
+		msg := recv()
+		if msg == `{"type":"done"}` {
+			break
+		}
+		var chunk completionChunk
+		if err := json.Unmarshal([]byte(msg), &chunk); err != nil {
+			t.Fatalf("Unexpected message %s", msg)
+		}
+		content.WriteString(chunk.Choices[0].Delta.Content)
+	}
+	if content.String() != "Hello" {
+		t.Errorf("Expected Hello, got %q", content.String())
+	}
+
+	// continue 带上之前的回复重新请求
+	send(`{"type":"continue"}`)
+	for recv() != `{"type":"done"}` {
+	}
+	msgs := provider.requests[1].Messages
+	if len(msgs) != 3 || msgs[1].Role != "assistant" || msgs[1].Content != "Hello" || msgs[2].Content != continuePrompt {
+		t.Errorf("Unexpected continue request %+v", msgs)
+	}
+}
+
+// lateProvider 首次请求输出一个块后，在取消之后仍输出若干块，模拟不及时响应取消的上游
+type lateProvider struct {
+	requests []*aichat.ChatRequest
+}
+
+func (p *lateProvider) IsAvailable() bool {
+	return true
+}
+
+func (p *lateProvider) StreamChat(ctx context.Context, req *aichat.ChatRequest) (<-chan aichat.StreamChunk, error) {
+	p.requests = append(p.requests, req)
+	first := len(p.requests) == 1
+	chunks := make(chan aichat.StreamChunk)
+	go func() {
+		defer close(chunks)
+		chunks <- aichat.StreamChunk{Content: "Hel"}
+		if !first {
+			return
+		}
+		<-ctx.Done()
+		for range 20 {
+			chunks <- aichat.StreamChunk{Content: "lo"}
+		}
+	}()
+	return chunks, nil
+}
+
+func TestServer_WebSocketCancelContinue(t *testing.T) {
+	provider := &lateProvider{}
+	factory := aichat.NewDefaultModelFactory()
+	factory.RegisterProvider("late", provider)
+	send, recv := dialChatWS(t, NewServer(factory))
+
+	send(`{"type":"chat","data":{"model":"late","messages":[{"role":"user","content":"hi"}]}}`)
+	var chunk completionChunk
+	if err := json.Unmarshal([]byte(recv()), &chunk); err != nil || chunk.Choices[0].Delta.Content != "Hel" {
+		t.Fatalf("Expected the Hel chunk, got %+v %v", chunk, err)
+	}
+	send(`{"type":"cancel"}`)
+	if got := recv(); got != `{"type":"cancelled"}` {
+		t.Fatalf("Expected cancelled, got %s", got)
+	}
+
+	// 取消后上游仍在输出的块没有发给客户端，也不计入 continue 的历史
+	send(`{"type":"continue"}`)
+	for recv() != `{"type":"done"}` {
+	}
+	if msgs := provider.requests[1].Messages; len(msgs) != 3 || msgs[1].Content != "Hel" {
+		t.Errorf("Expected continue to carry only the sent reply, got %+v", msgs)
+	}
+}
+
+func TestServer_WebSocketUpstreamError(t *testing.T) {
+	provider := &recordingProvider{fakeProvider: fakeProvider{chunks: []aichat.StreamChunk{
+		{Content: "Hi"}, {Error: errors.New("upstream failed")},
+	}}}
+	factory := aichat.NewDefaultModelFactory()
+	factory.RegisterProvider("broken", provider)
+	send, recv := dialChatWS(t, NewServer(factory))
+
+	// 中途出错时以错误结束本轮，不再发送 done
+	for range 2 {
+		send(`{"type":"chat","data":{"model":"broken","messages":[{"role":"user","content":"hi"}]}}`)
+		var chunk completionChunk
+		if err := json.Unmarshal([]byte(recv()), &chunk); err != nil || chunk.Choices[0].Delta.Content != "Hi" {
+			t.Fatalf("Expected the Hi chunk, got %+v %v", chunk, err)
+		}
+		if got := recv(); got != `{"type":"error","error":"upstream failed"}` {
+			t.Fatalf("Expected only an error frame, got %s", got)
+		}
+	}
+
+	// 只有已发送的内容计入回复
+	send(`{"type":"continue"}`)
+	recv()
+	recv()
+	if msgs := provider.requests[2].Messages; msgs[1].Content != "Hi" {
+		t.Errorf("Expected continue to carry the sent reply, got %+v", msgs)
+	}
+}
@@ -1,21 +1,189 @@
 package main
 
 import (
-  "fmt"
-)
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
 
-//TIP <p>To run your code, right-click the code and select <b>Run</b>.</p> <p>Alternatively, click
-// the <icon src="AllIcons.Actions.Execute"/> icon in the gutter and select the <b>Run</b> menu item from here.</p>
+	"chatlib/aichat"
+	"chatlib/batch"
+	"chatlib/gateway"
+	"chatlib/repl"
+	"chatlib/stream"
+)
 
 func main() {
-  //TIP <p>Press <shortcut actionId="ShowIntentionActions"/> when your caret is at the underlined text
-  // to see how GoLand suggests fixing the warning.</p><p>Alternatively, if available, click the lightbulb to view possible fixes.</p>
-  s := "gopher"
-  fmt.Printf("Hello and welcome, %s!\n", s)
-
-  for i := 1; i <= 5; i++ {
-	//TIP <p>To start your debugging session, right-click your code in the editor and select the Debug option.</p> <p>We have set one <icon src="AllIcons.Debugger.Db_set_breakpoint"/> breakpoint
-	// for you, but you can always add more by pressing <shortcut actionId="ToggleLineBreakpoint"/>.</p>
-	fmt.Println("i =", 100/i)
-  }
-}
\ No newline at end of file
+	cmd, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+
+	var err error
+	switch cmd {
+	case "serve":
+		err = runServe(args)
+	case "chat":
+		err = runChat(args)
+	case "batch":
+		err = runBatch(args)
+	default:
+		err = fmt.Errorf("unknown command %q, available: serve, chat, batch", cmd)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// runServe 启动 OpenAI 兼容网关，并提供可断线重连的后台生成接口
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":8080", "listen address")
+	flushInterval := fs.Duration("flush-interval", 0, "coalesce streamed tokens for up to this long")
+	flushBytes := fs.Int("flush-bytes", 0, "send coalesced tokens once this many bytes are pending")
+	flushSentences := fs.Bool("flush-sentences", false, "send coalesced tokens at sentence boundaries")
+	_ = fs.Parse(args)
+
+	factory := newModelFactory()
+	server := gateway.NewServer(factory)
+	policy := stream.FlushPolicy{Interval: *flushInterval, MaxBytes: *flushBytes}
+	if *flushSentences {
+		policy.Boundary = stream.SentenceBoundary
+	}
+	server.SetFlushPolicy(gateway.RouteChatCompletions, policy)
+	server.SetFlushPolicy(gateway.RouteChatWebSocket, policy)
+	gateway.NewGenerationManager(factory, gateway.GenerationOptions{}).Register(server)
+
+	log.Printf("serving models %v on %s", factory.ListAvailableModels(), *addr)
+	return http.ListenAndServe(*addr, server)
+}
+
+// runChat 在终端中与模型交互式对话
+func runChat(args []string) error {
+	fs := flag.NewFlagSet("chat", flag.ExitOnError)
+	modelName := fs.String("model", "mock", "model name")
+	system := fs.String("system", "", "system prompt")
+	_ = fs.Parse(args)
+
+	factory := newModelFactory()
+	if _, err := factory.GetProvider(*modelName); err != nil {
+		return err
+	}
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	r := &repl.REPL{
+		Factory:    factory,
+		Model:      *modelName,
+		System:     *system,
+		In:         os.Stdin,
+		Out:        os.Stdout,
+		Interrupts: interrupts,
+	}
+	return r.Run(context.Background())
+}
+
+// runBatch 执行 Batch 格式的 JSONL 请求文件，已在结果文件中完成的请求会被跳过
+func runBatch(args []string) error {
+	fs := flag.NewFlagSet("batch", flag.ExitOnError)
+	inputPath := fs.String("input", "batch.jsonl", "input JSONL file")
+	outputPath := fs.String("output", "results.jsonl", "results JSONL file, completed custom_ids are skipped")
+	errorsPath := fs.String("errors", "errors.jsonl", "errors JSONL file")
+	workers := fs.Int("workers", 4, "concurrent requests")
+	rate := fs.Float64("rate", 0, "max requests per second, 0 for unlimited")
+	_ = fs.Parse(args)
+
+	in, err := os.Open(*inputPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	results, done, err := batch.OpenOutput(*outputPath)
+	if err != nil {
+		return err
+	}
+	defer results.Close()
+
+	errs, err := os.OpenFile(*errorsPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer errs.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	runner := &batch.Runner{Factory: newModelFactory(), Workers: *workers, RateLimit: *rate}
+	summary, err := runner.Run(ctx, in, results, errs, done)
+	log.Printf("batch finished: %d total, %d skipped, %d succeeded, %d failed",
+		summary.Total, summary.Skipped, summary.Succeeded, summary.Failed)
+
+	// 去掉已重试成功或重复记录的错误
+	errs.Close()
+	if cerr := compactBatchErrors(*outputPath, *errorsPath); cerr != nil && err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// compactBatchErrors 按结果文件中已完成的请求整理错误文件
+func compactBatchErrors(outputPath, errorsPath string) error {
+	f, err := os.Open(outputPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	done, err := batch.Completed(f)
+	if err != nil {
+		return err
+	}
+	return batch.CompactErrors(errorsPath, done)
+}
+
+// newModelFactory 根据环境变量注册模型：
+//
+//	OPENAI_API_KEY / OPENAI_BASE_URL / OPENAI_MODELS
+//	DEEPSEEK_API_KEY / DEEPSEEK_BASE_URL / DEEPSEEK_MODELS
+//
+// BASE_URL 为 API 根地址，MODELS 为逗号分隔的模型名，另外总是注册一个 mock 模型用于调试
+func newModelFactory() *aichat.DefaultModelFactory {
+	factory := aichat.NewDefaultModelFactory()
+	factory.RegisterProvider("mock", aichat.NewMockProvider("mock"))
+
+	vendors := []struct {
+		prefix  string
+		baseURL string
+		models  string
+	}{
+		{"OPENAI", "https://api.openai.com/v1", "gpt-4o-mini"},
+		{"DEEPSEEK", "https://api.deepseek.com", "deepseek-chat"},
+	}
+	for _, v := range vendors {
+		apiKey := os.Getenv(v.prefix + "_API_KEY")
+		if apiKey == "" {
+			continue
+		}
+		provider := aichat.NewOpenAIProvider(apiKey, envOr(v.prefix+"_BASE_URL", v.baseURL))
+		for _, name := range strings.Split(envOr(v.prefix+"_MODELS", v.models), ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				factory.RegisterProvider(name, provider)
+			}
+		}
+	}
+	return factory
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
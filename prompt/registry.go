@@ -0,0 +1,281 @@
+package prompt
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+
+	"chatlib/aichat"
+)
+
+const (
+	templateExt     = ".tmpl"
+	partialPrefix   = "_"
+	frontMatterMark = "---"
+)
+
+// Registry 提示词模板仓库，同名模板可以有多个版本
+type Registry struct {
+	partials  *template.Template
+	templates map[string][]*Template // 按版本升序
+	mu        sync.RWMutex
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		partials:  template.New("").Funcs(funcs).Option("missingkey=error"),
+		templates: make(map[string][]*Template),
+	}
+}
+
+// LoadDir 从目录加载模板：以 _ 开头的文件为片段，其余 .tmpl 文件为模板
+func LoadDir(dir string) (*Registry, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+templateExt))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	r := NewRegistry()
+	// 片段需先于模板加载
+	for _, path := range paths {
+		base := filepath.Base(path)
+		if !strings.HasPrefix(base, partialPrefix) {
+			continue
+		}
+		text, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(base, partialPrefix), templateExt)
+		if err = r.AddPartial(name, string(text)); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+
+	for _, path := range paths {
+		base := filepath.Base(path)
+		if strings.HasPrefix(base, partialPrefix) {
+			continue
+		}
+		text, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = r.Add(strings.TrimSuffix(base, templateExt), string(text)); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	return r, nil
+}
+
+// AddPartial 注册可复用片段，模板中以 {{template "name" .}} 引用，需在引用它的模板之前注册
+func (r *Registry) AddPartial(name, text string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, err := r.partials.New(name).Parse(markRoles(text))
+	return err
+}
+
+// Add 解析并注册模板，front matter 未指定 name 时使用 defaultName
+func (r *Registry) Add(defaultName, source string) (*Template, error) {
+	t, body, err := parseFrontMatter(source)
+	if err != nil {
+		return nil, err
+	}
+	if t.Name == "" {
+		t.Name = defaultName
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	base, err := r.partials.Clone()
+	if err != nil {
+		return nil, err
+	}
+	if t.tmpl, err = base.New(t.Name).Parse(markRoles(body)); err != nil {
+		return nil, err
+	}
+
+	versions := r.templates[t.Name]
+	for _, v := range versions {
+		if v.Version == t.Version {
+			return nil, fmt.Errorf("prompt %s@%d already exists", t.Name, t.Version)
+		}
+	}
+	versions = append(versions, t)
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Version < versions[j].Version })
+	r.templates[t.Name] = versions
+	return t, nil
+}
+
+// Get 返回模板的最新版本
+func (r *Registry) Get(name string) (*Template, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	versions := r.templates[name]
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("prompt %s not found", name)
+	}
+	return versions[len(versions)-1], nil
+}
+
+// GetVersion 返回模板的指定版本
+func (r *Registry) GetVersion(name string, version int) (*Template, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, t := range r.templates[name] {
+		if t.Version == version {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("prompt %s@%d not found", name, version)
+}
+
+// Render 使用最新版本渲染模板
+func (r *Registry) Render(name string, vars map[string]any) ([]aichat.ChatMessage, error) {
+	t, err := r.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return t.Render(vars)
+}
+
+// List 返回全部模板名
+func (r *Registry) List() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.templates))
+	for name := range r.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseFrontMatter 解析模板头部的元信息：
+//
+//	---
+//	name: summarize
+//	version: 2
+//	description: 总结文章
+//	vars:
+//	  topic: string
+//	  max_words: int = 100
+//	---
+func parseFrontMatter(source string) (*Template, string, error) {
+	t := &Template{Version: 1}
+
+	lines := strings.Split(source, "\n")
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != frontMatterMark {
+		return t, source, nil
+	}
+
+	inVars := false
+	for i := 1; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r")
+		if strings.TrimSpace(line) == frontMatterMark {
+			return t, strings.Join(lines[i+1:], "\n"), nil
+		}
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, "", fmt.Errorf("invalid front matter line %d: %q", i+1, line)
+		}
+		value = strings.TrimSpace(value)
+
+		// 缩进的行属于 vars
+		if inVars && (strings.HasPrefix(key, " ") || strings.HasPrefix(key, "\t")) {
+			v, err := parseVariable(strings.TrimSpace(key), value)
+			if err != nil {
+				return nil, "", fmt.Errorf("line %d: %v", i+1, err)
+			}
+			t.Vars = append(t.Vars, v)
+			continue
+		}
+
+		inVars = false
+		switch strings.TrimSpace(key) {
+		case "name":
+			t.Name = value
+		case "version":
+			version, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, "", fmt.Errorf("invalid version %q", value)
+			}
+			t.Version = version
+		case "description":
+			t.Description = value
+		case "vars":
+			inVars = true
+		default:
+			return nil, "", fmt.Errorf("unknown front matter key %q", key)
+		}
+	}
+	return nil, "", fmt.Errorf("unterminated front matter")
+}
+
+// parseVariable 解析 "type" 或 "type = default"
+func parseVariable(name, spec string) (Variable, error) {
+	typ, def, hasDefault := strings.Cut(spec, "=")
+	v := Variable{Name: name, Type: VarType(strings.TrimSpace(typ))}
+	if !hasDefault {
+		if _, err := convert(v.Type, zeroValue(v.Type)); err != nil {
+			return v, err
+		}
+		return v, nil
+	}
+
+	def = strings.TrimSpace(def)
+	var err error
+	switch v.Type {
+	case String:
+		v.Default, err = strconv.Unquote(def)
+		if err != nil {
+			v.Default, err = def, nil
+		}
+	case Int:
+		v.Default, err = strconv.Atoi(def)
+	case Float:
+		v.Default, err = strconv.ParseFloat(def, 64)
+	case Bool:
+		v.Default, err = strconv.ParseBool(def)
+	default:
+		err = fmt.Errorf("type %s does not support default value", v.Type)
+	}
+	if err != nil {
+		return v, fmt.Errorf("variable %s: %v", name, err)
+	}
+	return v, nil
+}
+
+func zeroValue(typ VarType) any {
+	switch typ {
+	case String:
+		return ""
+	case Int:
+		return 0
+	case Float:
+		return 0.0
+	case Bool:
+		return false
+	case List:
+		return []string(nil)
+	case Examples:
+		return []Example(nil)
+	}
+	return nil
+}
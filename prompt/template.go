@@ -0,0 +1,206 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"chatlib/aichat"
+)
+
+// VarType 模板变量类型
+type VarType string
+
+const (
+	String   VarType = "string"
+	Int      VarType = "int"
+	Float    VarType = "float"
+	Bool     VarType = "bool"
+	List     VarType = "list"     // []string
+	Examples VarType = "examples" // []Example
+)
+
+// Variable 模板变量声明
+type Variable struct {
+	Name    string
+	Type    VarType
+	Default any // 默认值，为 nil 时变量必填
+}
+
+// Example few-shot 示例
+type Example struct {
+	Input  string `json:"input"`
+	Output string `json:"output"`
+}
+
+// Template 提示词模板，渲染结果为消息列表
+//
+// 模板源码中独占一行的 [system]、[user]、[assistant] 标记将渲染结果切分为消息，
+// 首个标记之前的内容视为 system 消息。变量值中的标记只是普通文本，不会切分消息。
+type Template struct {
+	Name        string
+	Version     int
+	Description string
+	Vars        []Variable
+
+	tmpl *template.Template
+}
+
+// Render 使用变量渲染模板，变量会按声明的类型校验
+func (t *Template) Render(vars map[string]any) ([]aichat.ChatMessage, error) {
+	data, err := t.bind(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	var sb strings.Builder
+	if err = t.tmpl.Execute(&sb, data); err != nil {
+		return nil, fmt.Errorf("render prompt %s@%d: %v", t.Name, t.Version, err)
+	}
+	return splitMessages(sb.String()), nil
+}
+
+// bind 校验变量并填充默认值
+func (t *Template) bind(vars map[string]any) (map[string]any, error) {
+	data := make(map[string]any, len(t.Vars))
+	for _, v := range t.Vars {
+		value, ok := vars[v.Name]
+		if !ok {
+			if v.Default == nil {
+				return nil, fmt.Errorf("prompt %s@%d: missing variable %s", t.Name, t.Version, v.Name)
+			}
+			data[v.Name] = v.Default
+			continue
+		}
+
+		value, err := convert(v.Type, value)
+		if err != nil {
+			return nil, fmt.Errorf("prompt %s@%d: variable %s: %v", t.Name, t.Version, v.Name, err)
+		}
+		data[v.Name] = value
+	}
+
+	for name := range vars {
+		if _, ok := data[name]; !ok {
+			return nil, fmt.Errorf("prompt %s@%d: undeclared variable %s", t.Name, t.Version, name)
+		}
+	}
+	return data, nil
+}
+
+// convert 将变量值转换为声明的类型
+func convert(typ VarType, value any) (any, error) {
+	switch typ {
+	case String:
+		if s, ok := value.(string); ok {
+			return sanitize(s), nil
+		}
+	case Int:
+		switch n := value.(type) {
+		case int:
+			return n, nil
+		case int64:
+			return int(n), nil
+		case int32:
+			return int(n), nil
+		}
+	case Float:
+		switch n := value.(type) {
+		case float64:
+			return n, nil
+		case float32:
+			return float64(n), nil
+		case int:
+			return float64(n), nil
+		}
+	case Bool:
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+	case List:
+		if l, ok := value.([]string); ok {
+			clean := make([]string, len(l))
+			for i, s := range l {
+				clean[i] = sanitize(s)
+			}
+			return clean, nil
+		}
+	case Examples:
+		if e, ok := value.([]Example); ok {
+			clean := make([]Example, len(e))
+			for i, ex := range e {
+				clean[i] = Example{Input: sanitize(ex.Input), Output: sanitize(ex.Output)}
+			}
+			return clean, nil
+		}
+	default:
+		return nil, fmt.Errorf("unknown type %s", typ)
+	}
+	return nil, fmt.Errorf("expected %s, got %T", typ, value)
+}
+
+var roleMarkers = map[string]string{
+	"[system]":    "system",
+	"[user]":      "user",
+	"[assistant]": "assistant",
+}
+
+// roleSentinel 源码中的角色标记在解析前替换为哨兵，变量值中的 NUL 会被去掉，无法伪造
+func roleSentinel(role string) string {
+	return "\x00role:" + role + "\x00"
+}
+
+// sanitize 去掉变量值中的 NUL，防止伪造角色哨兵
+func sanitize(s string) string {
+	return strings.ReplaceAll(s, "\x00", "")
+}
+
+// markRoles 将模板源码中独占一行的角色标记替换为哨兵
+func markRoles(source string) string {
+	lines := strings.Split(source, "\n")
+	for i, line := range lines {
+		if role, ok := roleMarkers[strings.TrimSpace(line)]; ok {
+			lines[i] = roleSentinel(role)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// splitMessages 按角色哨兵切分渲染结果，忽略空消息
+func splitMessages(text string) []aichat.ChatMessage {
+	var msgs []aichat.ChatMessage
+	role := "system"
+	var content strings.Builder
+
+	flush := func() {
+		if s := strings.TrimSpace(content.String()); s != "" {
+			msgs = append(msgs, aichat.ChatMessage{Role: role, Content: s})
+		}
+		content.Reset()
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		if r, ok := strings.CutPrefix(strings.TrimSpace(line), "\x00role:"); ok && strings.HasSuffix(r, "\x00") {
+			flush()
+			role = strings.TrimSuffix(r, "\x00")
+			continue
+		}
+		content.WriteString(line)
+		content.WriteString("\n")
+	}
+	flush()
+	return msgs
+}
+
+// funcs 模板内置函数
+var funcs = template.FuncMap{
+	// examples 将示例渲染为 user/assistant 消息对
+	"examples": func(examples []Example) string {
+		var sb strings.Builder
+		for _, e := range examples {
+			sb.WriteString("\n" + roleSentinel("user") + "\n" + e.Input + "\n" + roleSentinel("assistant") + "\n" + e.Output + "\n")
+		}
+		return sb.String()
+	},
+	"join": strings.Join,
+}
@@ -0,0 +1,86 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"chatlib/aichat"
+	"chatlib/splitter"
+	"chatlib/vectorstore"
+)
+
+const defaultIngestBatch = 64
+
+// Ingester 文档入库流程：切分 -> 向量化 -> 写入向量存储
+type Ingester struct {
+	Splitter  splitter.Splitter
+	Embedder  aichat.Embedder
+	Store     *vectorstore.Store
+	BatchSize int // 每批向量化的片段数，为 0 时使用默认值
+}
+
+// Ingest 切分并入库一篇文档，同一来源的旧片段会被替换，返回写入的片段数
+func (in *Ingester) Ingest(ctx context.Context, source, text string) (int, error) {
+	chunks := in.Splitter.Split(source, text)
+
+	batchSize := in.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultIngestBatch
+	}
+
+	for start := 0; start < len(chunks); start += batchSize {
+		batch := chunks[start:min(start+batchSize, len(chunks))]
+
+		input := make([]string, len(batch))
+		for i, c := range batch {
+			input[i] = c.Text
+		}
+		vectors, err := in.Embedder.Embed(ctx, input)
+		if err != nil {
+			return start, fmt.Errorf("embed %s: %w", source, err)
+		}
+		if len(vectors) != len(batch) {
+			return start, fmt.Errorf("embed %s: expected %d embeddings, got %d", source, len(batch), len(vectors))
+		}
+
+		docs := make([]vectorstore.Document, len(batch))
+		for i, c := range batch {
+			docs[i] = chunkDocument(c, start+i, vectors[i])
+		}
+		if err = in.Store.Add(docs...); err != nil {
+			return start, err
+		}
+	}
+
+	// 删除上一版本多出来的片段
+	for i := len(chunks); ; i++ {
+		id := chunkID(source, i)
+		if _, ok := in.Store.Get(id); !ok {
+			break
+		}
+		in.Store.Delete(id)
+	}
+	return len(chunks), nil
+}
+
+func chunkDocument(c splitter.Chunk, index int, vector []float32) vectorstore.Document {
+	metadata := map[string]string{
+		SourceKey: c.Source,
+		"start":   strconv.Itoa(c.Start),
+		"end":     strconv.Itoa(c.End),
+	}
+	for k, v := range c.Metadata {
+		metadata[k] = v
+	}
+	return vectorstore.Document{
+		ID:       chunkID(c.Source, index),
+		Text:     c.Text,
+		Metadata: metadata,
+		Vector:   vector,
+	}
+}
+
+func chunkID(source string, index int) string {
+	return source + "#" + strconv.Itoa(index)
+}
@@ -0,0 +1,122 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"chatlib/aichat"
+	"chatlib/vectorstore"
+)
+
+const (
+	defaultTopK         = 4
+	defaultContextTitle = "以下是与问题相关的参考资料，回答时请基于这些资料，并用 [编号] 标注引用来源；资料不足以回答时请直接说明。"
+	// SourceKey 文档来源在 Metadata 中的键
+	SourceKey = "source"
+)
+
+// Citation 注入到上下文中的引用
+type Citation struct {
+	Index  int     `json:"index"` // 引用编号，从 1 开始
+	ID     string  `json:"id"`
+	Source string  `json:"source,omitempty"`
+	Score  float64 `json:"score"`
+}
+
+// Retriever 检索器，为用户问题查找相关片段并注入到请求中
+type Retriever struct {
+	Store    *vectorstore.Store
+	Embedder aichat.Embedder
+	TopK     int     // 检索条数，为 0 时使用默认值
+	MinScore float64 // 低于该相似度的片段被忽略
+	Title    string  // 参考资料的说明文字，为空时使用默认值
+}
+
+// Retrieve 检索与 query 相关的片段
+func (r *Retriever) Retrieve(ctx context.Context, query string) ([]vectorstore.Result, error) {
+	vectors, err := r.Embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != 1 {
+		return nil, fmt.Errorf("expected 1 embedding, got %d", len(vectors))
+	}
+
+	topK := r.TopK
+	if topK <= 0 {
+		topK = defaultTopK
+	}
+
+	var results []vectorstore.Result
+	for _, result := range r.Store.Search(vectors[0], topK) {
+		if result.Score >= r.MinScore {
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// Augment 为最后一条用户消息检索参考资料，以 system 消息的形式插入到该消息之前
+func (r *Retriever) Augment(ctx context.Context, req *aichat.ChatRequest) ([]Citation, error) {
+	last := len(req.Messages) - 1
+	if last < 0 || req.Messages[last].Role != "user" {
+		return nil, nil
+	}
+
+	results, err := r.Retrieve(ctx, req.Messages[last].Content)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+
+	title := r.Title
+	if title == "" {
+		title = defaultContextTitle
+	}
+
+	var sb strings.Builder
+	sb.WriteString(title)
+	citations := make([]Citation, 0, len(results))
+	for i, result := range results {
+		c := Citation{Index: i + 1, ID: result.ID, Source: result.Metadata[SourceKey], Score: result.Score}
+		citations = append(citations, c)
+
+		sb.WriteString(fmt.Sprintf("\n\n[%d]", c.Index))
+		if c.Source != "" {
+			sb.WriteString(" 来源：" + c.Source)
+		}
+		sb.WriteString("\n" + result.Text)
+	}
+
+	msgs := make([]aichat.ChatMessage, 0, len(req.Messages)+1)
+	msgs = append(msgs, req.Messages[:last]...)
+	msgs = append(msgs, aichat.ChatMessage{Role: "system", Content: sb.String()})
+	req.Messages = append(msgs, req.Messages[last])
+	return citations, nil
+}
+
+// Provider 检索增强的模型提供者，每次对话前自动注入参考资料
+type Provider struct {
+	provider  aichat.ModelProvider
+	retriever *Retriever
+}
+
+func NewProvider(provider aichat.ModelProvider, retriever *Retriever) *Provider {
+	return &Provider{provider: provider, retriever: retriever}
+}
+
+func (p *Provider) IsAvailable() bool {
+	return p.provider.IsAvailable()
+}
+
+func (p *Provider) StreamChat(ctx context.Context, req *aichat.ChatRequest) (<-chan aichat.StreamChunk, error) {
+	augmented := *req
+	augmented.Messages = append([]aichat.ChatMessage(nil), req.Messages...)
+	if _, err := p.retriever.Augment(ctx, &augmented); err != nil {
+		return nil, fmt.Errorf("retrieve context: %w", err)
+	}
+	return p.provider.StreamChat(ctx, &augmented)
+}
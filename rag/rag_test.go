@@ -0,0 +1,104 @@
+package rag
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"chatlib/aichat"
+	"chatlib/splitter"
+	"chatlib/vectorstore"
+)
+
+// keywordEmbedder 按关键词生成向量
+type keywordEmbedder []string
+
+func (e keywordEmbedder) Embed(ctx context.Context, input []string) ([][]float32, error) {
+	vectors := make([][]float32, len(input))
+	for i, text := range input {
+		vectors[i] = make([]float32, len(e))
+		for j, kw := range e {
+			if strings.Contains(text, kw) {
+				vectors[i][j] = 1
+			}
+		}
+	}
+	return vectors, nil
+}
+
+func TestRetriever_Augment(t *testing.T) {
+	ctx := context.Background()
+	embedder := keywordEmbedder{"安装", "配置", "部署"}
+
+	store := vectorstore.NewStore(nil)
+	docs := []struct{ id, text, source string }{
+		{"install", "运行 go install 完成安装", "docs/install.md"},
+		{"config", "配置文件位于 config.yaml", "docs/config.md"},
+		{"deploy", "使用 docker 部署", "docs/deploy.md"},
+	}
+	for _, d := range docs {
+		vectors, _ := embedder.Embed(ctx, []string{d.text})
+		err := store.Add(vectorstore.Document{ID: d.id, Text: d.text, Vector: vectors[0], Metadata: map[string]string{SourceKey: d.source}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	retriever := &Retriever{Store: store, Embedder: embedder, TopK: 2, MinScore: 0.5}
+	req := &aichat.ChatRequest{Messages: []aichat.ChatMessage{
+		{Role: "system", Content: "sys"},
+		{Role: "user", Content: "如何修改配置？"},
+	}}
+
+	citations, err := retriever.Augment(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(citations) != 1 || citations[0].ID != "config" || citations[0].Source != "docs/config.md" {
+		t.Fatalf("Expected citation for config, got %+v", citations)
+	}
+
+	if len(req.Messages) != 3 || req.Messages[1].Role != "system" || req.Messages[2].Role != "user" {
+		t.Fatalf("Expected context to be inserted before the question, got %+v", req.Messages)
+	}
+	if content := req.Messages[1].Content; !strings.Contains(content, "[1] 来源：docs/config.md\n配置文件位于 config.yaml") {
+		t.Errorf("Unexpected context message %q", content)
+	}
+
+	// 没有相关资料时不修改请求
+	req = &aichat.ChatRequest{Messages: []aichat.ChatMessage{{Role: "user", Content: "你好"}}}
+	if citations, _ = retriever.Augment(ctx, req); len(citations) != 0 || len(req.Messages) != 1 {
+		t.Errorf("Expected request untouched, got %+v", req.Messages)
+	}
+}
+
+func TestIngester_Ingest(t *testing.T) {
+	ctx := context.Background()
+	store := vectorstore.NewStore(nil)
+	ingester := &Ingester{
+		Splitter:  &splitter.MarkdownSplitter{MaxSize: 100},
+		Embedder:  keywordEmbedder{"安装", "配置"},
+		Store:     store,
+		BatchSize: 1,
+	}
+
+	n, err := ingester.Ingest(ctx, "guide.md", "# 安装\n运行安装脚本\n\n# 配置\n编辑配置文件\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 || store.Len() != 2 {
+		t.Fatalf("Expected 2 chunks, got %d (store has %d)", n, store.Len())
+	}
+	doc, ok := store.Get("guide.md#1")
+	if !ok || doc.Metadata[SourceKey] != "guide.md" || doc.Metadata[splitter.HeadingKey] != "配置" || doc.Metadata["start"] != "29" {
+		t.Errorf("Unexpected document %+v", doc)
+	}
+
+	// 重新入库更短的版本时删除多余的旧片段
+	if _, err = ingester.Ingest(ctx, "guide.md", "# 安装\n运行安装脚本\n"); err != nil {
+		t.Fatal(err)
+	}
+	if store.Len() != 1 {
+		t.Errorf("Expected stale chunks to be removed, store has %d", store.Len())
+	}
+}
@@ -0,0 +1,263 @@
+package repl
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"chatlib/aichat"
+)
+
+const (
+	prompt         = "> "
+	continuePrompt = "… "
+	fence          = `"""`
+)
+
+const helpText = `命令：
+  /model [name]    查看或切换模型
+  /system [text]   查看或设置系统提示词，/system - 清空
+  /reset           清空对话历史
+  /save <file>     保存对话到文件
+  /load <file>     从文件加载对话
+  /help            显示帮助
+  /exit            退出
+多行输入：行尾加 \ 续行，或用 """ 包围多行内容。
+生成过程中按 Ctrl-C 仅取消本次回复。`
+
+// transcript 保存到文件的对话内容
+type transcript struct {
+	Model    string               `json:"model"`
+	System   string               `json:"system,omitempty"`
+	Messages []aichat.ChatMessage `json:"messages"`
+}
+
+// REPL 交互式终端对话
+type REPL struct {
+	Factory    aichat.ModelFactory
+	Model      string
+	System     string
+	In         io.Reader
+	Out        io.Writer
+	Interrupts <-chan os.Signal // Ctrl-C 信号，生成时取消本次回复，空闲时退出
+
+	messages []aichat.ChatMessage
+}
+
+// Run 运行交互循环，输入结束或收到 /exit 时返回
+func (r *REPL) Run(ctx context.Context) error {
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(r.In)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	fmt.Fprintf(r.Out, "当前模型 %s，输入 /help 查看命令\n", r.Model)
+	for {
+		fmt.Fprint(r.Out, prompt)
+		input, ok, err := r.readInput(ctx, lines)
+		if err != nil || !ok {
+			fmt.Fprintln(r.Out)
+			return err
+		}
+
+		input = strings.TrimSpace(input)
+		if input == "" {
+			continue
+		}
+		if strings.HasPrefix(input, "/") {
+			if quit := r.command(input); quit {
+				return nil
+			}
+			continue
+		}
+		r.chat(ctx, input)
+	}
+}
+
+// readInput 读取一条完整输入，处理续行和多行块；输入结束或空闲时收到中断返回 false
+func (r *REPL) readInput(ctx context.Context, lines <-chan string) (string, bool, error) {
+	var sb strings.Builder
+	inBlock := false
+	for {
+		var line string
+		var ok bool
+		select {
+		case <-ctx.Done():
+			return "", false, ctx.Err()
+		case <-r.Interrupts:
+			return "", false, nil
+		case line, ok = <-lines:
+			if !ok {
+				return sb.String(), sb.Len() > 0, nil
+			}
+		}
+
+		switch {
+		case strings.TrimSpace(line) == fence:
+			if inBlock {
+				return sb.String(), true, nil
+			}
+			inBlock = true
+		case inBlock:
+			sb.WriteString(line + "\n")
+		case strings.HasSuffix(line, `\`):
+			sb.WriteString(strings.TrimSuffix(line, `\`) + "\n")
+		default:
+			sb.WriteString(line)
+			return sb.String(), true, nil
+		}
+		fmt.Fprint(r.Out, continuePrompt)
+	}
+}
+
+// chat 发送一轮对话，流式输出回复；中断时丢弃本轮
+func (r *REPL) chat(ctx context.Context, input string) {
+	provider, err := r.Factory.GetProvider(r.Model)
+	if err != nil {
+		fmt.Fprintf(r.Out, "[错误] %v\n", err)
+		return
+	}
+
+	req := &aichat.ChatRequest{Model: r.Model, Stream: true}
+	if r.System != "" {
+		req.Messages = append(req.Messages, aichat.ChatMessage{Role: "system", Content: r.System})
+	}
+	req.Messages = append(req.Messages, r.messages...)
+	req.Messages = append(req.Messages, aichat.ChatMessage{Role: "user", Content: input})
+
+	genCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-genCtx.Done():
+		case <-r.Interrupts:
+			cancel()
+		}
+	}()
+
+	chunks, err := provider.StreamChat(genCtx, req)
+	if err != nil {
+		fmt.Fprintf(r.Out, "[错误] %v\n", err)
+		return
+	}
+
+	var reply strings.Builder
+	for chunk := range chunks {
+		if chunk.Error != nil {
+			err = chunk.Error
+			continue
+		}
+		fmt.Fprint(r.Out, chunk.Content)
+		reply.WriteString(chunk.Content)
+	}
+	fmt.Fprintln(r.Out)
+
+	switch {
+	case genCtx.Err() != nil && ctx.Err() == nil:
+		fmt.Fprintln(r.Out, "[已取消]")
+	case err != nil:
+		fmt.Fprintf(r.Out, "[错误] %v\n", err)
+	default:
+		r.messages = append(r.messages,
+			aichat.ChatMessage{Role: "user", Content: input},
+			aichat.ChatMessage{Role: "assistant", Content: reply.String()},
+		)
+	}
+}
+
+// command 执行斜杠命令，返回是否退出
+func (r *REPL) command(input string) bool {
+	name, arg, _ := strings.Cut(input, " ")
+	arg = strings.TrimSpace(arg)
+
+	switch name {
+	case "/exit", "/quit":
+		return true
+	case "/help":
+		fmt.Fprintln(r.Out, helpText)
+	case "/model":
+		if arg == "" {
+			models := r.Factory.ListAvailableModels()
+			sort.Strings(models)
+			fmt.Fprintf(r.Out, "当前模型 %s，可用模型：%s\n", r.Model, strings.Join(models, ", "))
+			break
+		}
+		if _, err := r.Factory.GetProvider(arg); err != nil {
+			fmt.Fprintf(r.Out, "[错误] %v\n", err)
+			break
+		}
+		r.Model = arg
+		fmt.Fprintf(r.Out, "已切换到 %s\n", arg)
+	case "/system":
+		switch arg {
+		case "":
+			fmt.Fprintf(r.Out, "系统提示词：%s\n", r.System)
+		case "-":
+			r.System = ""
+			fmt.Fprintln(r.Out, "已清空系统提示词")
+		default:
+			r.System = arg
+			fmt.Fprintln(r.Out, "已设置系统提示词")
+		}
+	case "/reset":
+		r.messages = nil
+		fmt.Fprintln(r.Out, "已清空对话历史")
+	case "/save":
+		if err := r.save(arg); err != nil {
+			fmt.Fprintf(r.Out, "[错误] %v\n", err)
+			break
+		}
+		fmt.Fprintf(r.Out, "已保存到 %s\n", arg)
+	case "/load":
+		if err := r.load(arg); err != nil {
+			fmt.Fprintf(r.Out, "[错误] %v\n", err)
+			break
+		}
+		fmt.Fprintf(r.Out, "已加载 %d 条消息，当前模型 %s\n", len(r.messages), r.Model)
+	default:
+		fmt.Fprintf(r.Out, "未知命令 %s，输入 /help 查看命令\n", name)
+	}
+	return false
+}
+
+func (r *REPL) save(path string) error {
+	if path == "" {
+		return errors.New("usage: /save <file>")
+	}
+	data, err := json.MarshalIndent(transcript{Model: r.Model, System: r.System, Messages: r.messages}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func (r *REPL) load(path string) error {
+	if path == "" {
+		return errors.New("usage: /load <file>")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var t transcript
+	if err = json.Unmarshal(data, &t); err != nil {
+		return fmt.Errorf("invalid transcript %s: %v", path, err)
+	}
+	if t.Model != "" {
+		r.Model = t.Model
+	}
+	r.System = t.System
+	r.messages = t.Messages
+	return nil
+}
@@ -0,0 +1,141 @@
+package repl
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"chatlib/aichat"
+)
+
+// echoProvider 回显最后一条用户消息，并记录收到的请求
+type echoProvider struct {
+	requests []*aichat.ChatRequest
+}
+
+func (p *echoProvider) IsAvailable() bool {
+	return true
+}
+
+func (p *echoProvider) StreamChat(ctx context.Context, req *aichat.ChatRequest) (<-chan aichat.StreamChunk, error) {
+	p.requests = append(p.requests, req)
+	chunks := make(chan aichat.StreamChunk, 2)
+	chunks <- aichat.StreamChunk{Content: "echo: " + req.Messages[len(req.Messages)-1].Content}
+	chunks <- aichat.StreamChunk{FinishReason: "stop"}
+	close(chunks)
+	return chunks, nil
+}
+
+// blockingProvider 输出一个块后阻塞，直到上下文被取消
+type blockingProvider struct {
+	started chan struct{}
+}
+
+func (p *blockingProvider) IsAvailable() bool {
+	return true
+}
+
+func (p *blockingProvider) StreamChat(ctx context.Context, req *aichat.ChatRequest) (<-chan aichat.StreamChunk, error) {
+	chunks := make(chan aichat.StreamChunk)
+	go func() {
+		defer close(chunks)
+		chunks <- aichat.StreamChunk{Content: "partial"}
+		close(p.started)
+		<-ctx.Done()
+		chunks <- aichat.StreamChunk{Error: ctx.Err()}
+	}()
+	return chunks, nil
+}
+
+func newTestREPL(in io.Reader) (*REPL, *echoProvider, *strings.Builder) {
+	echo := &echoProvider{}
+	factory := aichat.NewDefaultModelFactory()
+	factory.RegisterProvider("echo", echo)
+	factory.RegisterProvider("other", echo)
+
+	out := &strings.Builder{}
+	return &REPL{Factory: factory, Model: "echo", In: in, Out: out}, echo, out
+}
+
+func TestREPL_ChatAndCommands(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chat.json")
+	input := strings.Join([]string{
+		"/system be brief",
+		"hello",
+		`line one\`,
+		"line two",
+		`"""`,
+		"block a",
+		"block b",
+		`"""`,
+		"/model other",
+		"/model missing",
+		"/save " + path,
+		"/reset",
+		"after reset",
+		"/load " + path,
+		"/exit",
+		"never sent",
+	}, "\n")
+
+	r, echo, out := newTestREPL(strings.NewReader(input))
+	if err := r.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(echo.requests) != 4 {
+		t.Fatalf("Expected 4 requests, got %d\n%s", len(echo.requests), out)
+	}
+	first := echo.requests[0]
+	if first.Messages[0].Role != "system" || first.Messages[0].Content != "be brief" {
+		t.Errorf("Expected system prompt, got %+v", first.Messages)
+	}
+	if got := echo.requests[1].Messages; got[len(got)-1].Content != "line one\nline two" || len(got) != 4 {
+		t.Errorf("Unexpected continuation request %+v", got)
+	}
+	if got := echo.requests[2].Messages; got[len(got)-1].Content != "block a\nblock b" {
+		t.Errorf("Unexpected block request %+v", got)
+	}
+	if got := echo.requests[3]; got.Model != "other" || len(got.Messages) != 2 {
+		t.Errorf("Expected reset history on model other, got %s %+v", got.Model, got.Messages)
+	}
+
+	// /load 恢复保存时的历史
+	if len(r.messages) != 6 || r.messages[5].Content != "echo: block a\nblock b" || r.System != "be brief" {
+		t.Errorf("Unexpected loaded state %+v", r.messages)
+	}
+	if !strings.Contains(out.String(), "echo: hello") || !strings.Contains(out.String(), "[错误]") {
+		t.Errorf("Unexpected output:\n%s", out)
+	}
+}
+
+func TestREPL_InterruptCancelsGeneration(t *testing.T) {
+	blocking := &blockingProvider{started: make(chan struct{})}
+	factory := aichat.NewDefaultModelFactory()
+	factory.RegisterProvider("slow", blocking)
+
+	inR, inW := io.Pipe()
+	interrupts := make(chan os.Signal)
+	out := &strings.Builder{}
+	r := &REPL{Factory: factory, Model: "slow", In: inR, Out: out, Interrupts: interrupts}
+
+	done := make(chan error)
+	go func() { done <- r.Run(context.Background()) }()
+
+	_, _ = io.WriteString(inW, "hi\n")
+	<-blocking.started
+	interrupts <- os.Interrupt
+
+	// 取消后仍能继续输入，空闲时中断则退出
+	_, _ = io.WriteString(inW, "/reset\n")
+	interrupts <- os.Interrupt
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "partial\n[已取消]") || len(r.messages) != 0 {
+		t.Errorf("Expected cancelled turn to be discarded, output:\n%s", out)
+	}
+}
@@ -0,0 +1,108 @@
+package splitter
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+
+	"chatlib/aichat"
+)
+
+// Go 代码片段的 Metadata 键
+const (
+	PackageKey = "package"
+	KindKey    = "kind" // package, func, method, type, const, var
+	NameKey    = "name" // 方法为 Recv.Name，多个声明以逗号分隔
+)
+
+var codeSeparators = []string{"\n\n", "\n"}
+
+// GoSplitter 按顶层声明切分 Go 源码，声明连同文档注释作为一个片段，
+// package 子句和 import 合并为首个片段；解析失败时退化为按行切分
+type GoSplitter struct {
+	MaxSize int                 // 片段最大长度，过长的声明按空行和行切分
+	Counter aichat.TokenCounter // 长度计算方式，为空时按字符数
+}
+
+func (s *GoSplitter) Split(source, text string) []Chunk {
+	length := lengthFunc(s.Counter)
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, source, text, parser.ParseComments)
+	if err != nil {
+		spans := splitRecursive(text, span{0, len(text)}, codeSeparators, s.MaxSize, length)
+		return makeChunks(source, text, spans, nil)
+	}
+
+	offset := func(pos token.Pos) int {
+		return fset.Position(pos).Offset
+	}
+	pkg := file.Name.Name
+
+	// package 子句、文档注释和 import
+	header := span{0, offset(file.Name.End())}
+	decls := file.Decls
+	for len(decls) > 0 {
+		gen, ok := decls[0].(*ast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT {
+			break
+		}
+		header.end = offset(gen.End())
+		decls = decls[1:]
+	}
+	chunks := makeChunks(source, text, []span{header}, map[string]string{PackageKey: pkg, KindKey: "package", NameKey: pkg})
+
+	for _, decl := range decls {
+		start, kind, name := offset(decl.Pos()), "", ""
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Doc != nil {
+				start = offset(d.Doc.Pos())
+			}
+			kind, name = "func", d.Name.Name
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				kind, name = "method", receiverName(d.Recv.List[0].Type)+"."+name
+			}
+		case *ast.GenDecl:
+			if d.Doc != nil {
+				start = offset(d.Doc.Pos())
+			}
+			kind, name = d.Tok.String(), strings.Join(specNames(d), ",")
+		}
+
+		metadata := map[string]string{PackageKey: pkg, KindKey: kind, NameKey: name}
+		spans := splitRecursive(text, span{start, offset(decl.End())}, codeSeparators, s.MaxSize, length)
+		chunks = append(chunks, makeChunks(source, text, spans, metadata)...)
+	}
+	return chunks
+}
+
+func receiverName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(t.X)
+	case *ast.IndexExpr:
+		return receiverName(t.X)
+	case *ast.IndexListExpr:
+		return receiverName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+func specNames(d *ast.GenDecl) []string {
+	var names []string
+	for _, spec := range d.Specs {
+		switch s := spec.(type) {
+		case *ast.TypeSpec:
+			names = append(names, s.Name.Name)
+		case *ast.ValueSpec:
+			for _, n := range s.Names {
+				names = append(names, n.Name)
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,100 @@
+package splitter
+
+import (
+	"strings"
+
+	"chatlib/aichat"
+)
+
+// HeadingKey 片段所属标题路径在 Metadata 中的键，如 "安装 > 使用 Docker"
+const HeadingKey = "heading"
+
+// MarkdownSplitter 按标题切分 Markdown 章节，过长的章节再按段落递归切分
+type MarkdownSplitter struct {
+	MaxSize int                 // 片段最大长度
+	Counter aichat.TokenCounter // 长度计算方式，为空时按字符数
+}
+
+type markdownSection struct {
+	span
+	headings []string
+}
+
+func (s *MarkdownSplitter) Split(source, text string) []Chunk {
+	length := lengthFunc(s.Counter)
+
+	var chunks []Chunk
+	for _, section := range splitSections(text) {
+		var metadata map[string]string
+		if len(section.headings) > 0 {
+			metadata = map[string]string{HeadingKey: strings.Join(section.headings, " > ")}
+		}
+		spans := splitRecursive(text, section.span, defaultSeparators, s.MaxSize, length)
+		chunks = append(chunks, makeChunks(source, text, spans, metadata)...)
+	}
+	return chunks
+}
+
+// splitSections 按 ATX 标题切分章节，忽略代码块中的 # 行
+func splitSections(text string) []markdownSection {
+	var sections []markdownSection
+	var headings []string
+	current := markdownSection{}
+	inFence := false
+
+	for offset := 0; offset < len(text); {
+		end := strings.IndexByte(text[offset:], '\n')
+		if end < 0 {
+			end = len(text)
+		} else {
+			end += offset + 1
+		}
+		line := strings.TrimRight(text[offset:end], "\r\n")
+
+		if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+		} else if level, title := parseHeading(line); !inFence && level > 0 {
+			current.end = offset
+			if current.end > current.start {
+				sections = append(sections, current)
+			}
+			if level <= len(headings) {
+				headings = headings[:level-1]
+			}
+			for len(headings) < level-1 {
+				headings = append(headings, "")
+			}
+			headings = append(headings, title)
+			current = markdownSection{span: span{start: offset}, headings: compact(headings)}
+		}
+		offset = end
+	}
+
+	current.end = len(text)
+	if current.end > current.start {
+		sections = append(sections, current)
+	}
+	return sections
+}
+
+// parseHeading 解析 "## 标题"，返回级别和标题文字
+func parseHeading(line string) (int, string) {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || (level < len(line) && line[level] != ' ' && line[level] != '\t') {
+		return 0, ""
+	}
+	return level, strings.TrimSpace(strings.TrimRight(strings.TrimSpace(line[level:]), "#"))
+}
+
+func compact(headings []string) []string {
+	var result []string
+	for _, h := range headings {
+		if h != "" {
+			result = append(result, h)
+		}
+	}
+	return result
+}
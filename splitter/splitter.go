@@ -0,0 +1,158 @@
+package splitter
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"chatlib/aichat"
+)
+
+// Chunk 切分出的文本片段，Start/End 为在原文中的字节偏移
+type Chunk struct {
+	Text     string            `json:"text"`
+	Source   string            `json:"source"`
+	Start    int               `json:"start"`
+	End      int               `json:"end"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// Splitter 文本切分器
+type Splitter interface {
+	Split(source, text string) []Chunk
+}
+
+// ForFile 根据文件扩展名选择切分器，未知类型使用按段落递归切分
+func ForFile(path string, maxSize int, counter aichat.TokenCounter) Splitter {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		return &MarkdownSplitter{MaxSize: maxSize, Counter: counter}
+	case ".go":
+		return &GoSplitter{MaxSize: maxSize, Counter: counter}
+	default:
+		return &RecursiveSplitter{MaxSize: maxSize, Counter: counter}
+	}
+}
+
+// RecursiveSplitter 依次按段落、行、句子、空格递归切分，再合并相邻的小片段
+type RecursiveSplitter struct {
+	MaxSize    int                 // 片段最大长度
+	Counter    aichat.TokenCounter // 长度计算方式，为空时按字符数
+	Separators []string            // 为空时使用默认分隔符
+}
+
+var defaultSeparators = []string{"\n\n", "\n", "。", ". ", " "}
+
+func (s *RecursiveSplitter) Split(source, text string) []Chunk {
+	seps := s.Separators
+	if len(seps) == 0 {
+		seps = defaultSeparators
+	}
+	return makeChunks(source, text, splitRecursive(text, span{0, len(text)}, seps, s.MaxSize, lengthFunc(s.Counter)), nil)
+}
+
+// span 原文中的字节区间
+type span struct {
+	start, end int
+}
+
+func lengthFunc(counter aichat.TokenCounter) func(string) int {
+	if counter == nil {
+		return utf8.RuneCountInString
+	}
+	return counter.Count
+}
+
+// splitRecursive 切分 text[sp.start:sp.end] 使每段长度不超过 maxSize，并合并相邻小段
+func splitRecursive(text string, sp span, seps []string, maxSize int, length func(string) int) []span {
+	if maxSize <= 0 || length(text[sp.start:sp.end]) <= maxSize {
+		return []span{sp}
+	}
+	if len(seps) == 0 {
+		return hardSplit(text, sp, maxSize, length)
+	}
+
+	// 按当前分隔符切开，分隔符保留在前一段末尾
+	var pieces []span
+	start := sp.start
+	for start < sp.end {
+		i := strings.Index(text[start:sp.end], seps[0])
+		if i < 0 {
+			break
+		}
+		end := start + i + len(seps[0])
+		pieces = append(pieces, span{start, end})
+		start = end
+	}
+	if start < sp.end {
+		pieces = append(pieces, span{start, sp.end})
+	}
+	if len(pieces) == 1 {
+		return splitRecursive(text, sp, seps[1:], maxSize, length)
+	}
+
+	var result []span
+	for _, p := range pieces {
+		result = append(result, splitRecursive(text, p, seps[1:], maxSize, length)...)
+	}
+	return mergeSpans(text, result, maxSize, length)
+}
+
+// mergeSpans 合并相邻的片段，合并后长度不超过 maxSize
+func mergeSpans(text string, spans []span, maxSize int, length func(string) int) []span {
+	var merged []span
+	for _, sp := range spans {
+		if n := len(merged); n > 0 && length(text[merged[n-1].start:sp.end]) <= maxSize {
+			merged[n-1].end = sp.end
+			continue
+		}
+		merged = append(merged, sp)
+	}
+	return merged
+}
+
+// hardSplit 没有可用分隔符时按字符二分切分
+func hardSplit(text string, sp span, maxSize int, length func(string) int) []span {
+	if length(text[sp.start:sp.end]) <= maxSize || utf8.RuneCountInString(text[sp.start:sp.end]) <= 1 {
+		return []span{sp}
+	}
+	mid := sp.start + (sp.end-sp.start)/2
+	for mid > sp.start && !utf8.RuneStart(text[mid]) {
+		mid--
+	}
+	if mid == sp.start {
+		_, size := utf8.DecodeRuneInString(text[sp.start:])
+		mid = sp.start + size
+	}
+	left := hardSplit(text, span{sp.start, mid}, maxSize, length)
+	right := hardSplit(text, span{mid, sp.end}, maxSize, length)
+	return mergeSpans(text, append(left, right...), maxSize, length)
+}
+
+// makeChunks 将区间转换为片段，去掉首尾空白并跳过空片段
+func makeChunks(source, text string, spans []span, metadata map[string]string) []Chunk {
+	var chunks []Chunk
+	for _, sp := range spans {
+		start, end := trimSpan(text, sp)
+		if start >= end {
+			continue
+		}
+		chunk := Chunk{Text: text[start:end], Source: source, Start: start, End: end}
+		if len(metadata) > 0 {
+			chunk.Metadata = make(map[string]string, len(metadata))
+			for k, v := range metadata {
+				chunk.Metadata[k] = v
+			}
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+func trimSpan(text string, sp span) (int, int) {
+	s := text[sp.start:sp.end]
+	trimmed := strings.TrimLeft(s, " \t\r\n")
+	start := sp.start + len(s) - len(trimmed)
+	end := start + len(strings.TrimRight(trimmed, " \t\r\n"))
+	return start, end
+}
@@ -0,0 +1,139 @@
+package splitter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// byteTokenizer 每个字节一个 token，多字节字符会被拆开
+type byteTokenizer struct{}
+
+func (byteTokenizer) Encode(text string) []int {
+	tokens := make([]int, len(text))
+	for i := 0; i < len(text); i++ {
+		tokens[i] = int(text[i])
+	}
+	return tokens
+}
+
+func (byteTokenizer) Decode(tokens []int) string {
+	b := make([]byte, len(tokens))
+	for i, t := range tokens {
+		b[i] = byte(t)
+	}
+	return string(b)
+}
+
+func texts(chunks []Chunk) []string {
+	var result []string
+	for _, c := range chunks {
+		result = append(result, c.Text)
+	}
+	return result
+}
+
+func checkOffsets(t *testing.T, text string, chunks []Chunk) {
+	t.Helper()
+	for _, c := range chunks {
+		if text[c.Start:c.End] != c.Text {
+			t.Errorf("Chunk offsets [%d:%d] do not match text %q", c.Start, c.End, c.Text)
+		}
+	}
+}
+
+func TestTokenSplitter_Split(t *testing.T) {
+	text := "abcdefghij"
+	chunks := (&TokenSplitter{Tokenizer: byteTokenizer{}, Size: 4, Overlap: 1}).Split("a.txt", text)
+	if expected := []string{"abcd", "defg", "ghij"}; !reflect.DeepEqual(texts(chunks), expected) {
+		t.Errorf("Expected %q, got %q", expected, texts(chunks))
+	}
+	checkOffsets(t, text, chunks)
+
+	// 窗口边界落在多字节字符中间时对齐到字符边界
+	text = "中文分词"
+	chunks = (&TokenSplitter{Tokenizer: byteTokenizer{}, Size: 5}).Split("b.txt", text)
+	if expected := []string{"中文", "分词"}; !reflect.DeepEqual(texts(chunks), expected) {
+		t.Errorf("Expected %q, got %q", expected, texts(chunks))
+	}
+	checkOffsets(t, text, chunks)
+}
+
+func TestRecursiveSplitter_Split(t *testing.T) {
+	text := "first paragraph.\n\nsecond paragraph is longer than the limit. it has two sentences.\n\nthird"
+	chunks := (&RecursiveSplitter{MaxSize: 40}).Split("doc.txt", text)
+	expected := []string{"first paragraph.", "second paragraph is longer than the", "limit. it has two sentences.\n\nthird"}
+	if !reflect.DeepEqual(texts(chunks), expected) {
+		t.Errorf("Expected %q, got %q", expected, texts(chunks))
+	}
+	checkOffsets(t, text, chunks)
+	for _, c := range chunks {
+		if len(c.Text) > 40 {
+			t.Errorf("Chunk %q exceeds max size", c.Text)
+		}
+	}
+}
+
+func TestMarkdownSplitter_Split(t *testing.T) {
+	text := "# 指南\n简介\n\n## 安装\n运行 go install\n\n```sh\n# 不是标题\n```\n\n## 配置\n编辑 config.yaml\n"
+	chunks := (&MarkdownSplitter{MaxSize: 100}).Split("guide.md", text)
+
+	expected := []string{
+		"# 指南\n简介",
+		"## 安装\n运行 go install\n\n```sh\n# 不是标题\n```",
+		"## 配置\n编辑 config.yaml",
+	}
+	if !reflect.DeepEqual(texts(chunks), expected) {
+		t.Fatalf("Expected %q, got %q", expected, texts(chunks))
+	}
+	if heading := chunks[1].Metadata[HeadingKey]; heading != "指南 > 安装" {
+		t.Errorf("Expected heading path, got %q", heading)
+	}
+	checkOffsets(t, text, chunks)
+}
+
+func TestGoSplitter_Split(t *testing.T) {
+	text := `// Package demo 示例
+package demo
+
+import "fmt"
+
+// Greeter 问候
+type Greeter struct{}
+
+// Hello 打招呼
+func (g *Greeter) Hello() {
+	fmt.Println("hello")
+}
+
+const a, b = 1, 2
+`
+	chunks := (&GoSplitter{MaxSize: 1000}).Split("demo.go", text)
+	if len(chunks) != 4 {
+		t.Fatalf("Expected 4 chunks, got %q", texts(chunks))
+	}
+	if !strings.HasPrefix(chunks[0].Text, "// Package demo") || !strings.HasSuffix(chunks[0].Text, `import "fmt"`) {
+		t.Errorf("Unexpected header chunk %q", chunks[0].Text)
+	}
+
+	expected := []map[string]string{
+		{PackageKey: "demo", KindKey: "package", NameKey: "demo"},
+		{PackageKey: "demo", KindKey: "type", NameKey: "Greeter"},
+		{PackageKey: "demo", KindKey: "method", NameKey: "Greeter.Hello"},
+		{PackageKey: "demo", KindKey: "const", NameKey: "a,b"},
+	}
+	for i, c := range chunks {
+		if !reflect.DeepEqual(c.Metadata, expected[i]) {
+			t.Errorf("Expected metadata %v, got %v", expected[i], c.Metadata)
+		}
+	}
+	if !strings.HasPrefix(chunks[2].Text, "// Hello 打招呼") {
+		t.Errorf("Expected doc comment to be included, got %q", chunks[2].Text)
+	}
+	checkOffsets(t, text, chunks)
+
+	// 语法错误时退化为按行切分
+	if chunks = (&GoSplitter{MaxSize: 1000}).Split("bad.go", "not go code"); len(chunks) != 1 {
+		t.Errorf("Expected fallback chunk, got %q", texts(chunks))
+	}
+}
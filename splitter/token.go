@@ -0,0 +1,58 @@
+package splitter
+
+import (
+	"unicode/utf8"
+)
+
+// Tokenizer 编解码接口，tokenizer.Encoding 即实现了该接口
+type Tokenizer interface {
+	Encode(text string) []int
+	Decode(tokens []int) string
+}
+
+// TokenSplitter 按固定 token 窗口切分，相邻窗口有 Overlap 个 token 重叠
+type TokenSplitter struct {
+	Tokenizer Tokenizer
+	Size      int // 窗口大小
+	Overlap   int // 重叠 token 数，需小于 Size
+}
+
+func (s *TokenSplitter) Split(source, text string) []Chunk {
+	if s.Size <= 0 {
+		return makeChunks(source, text, []span{{0, len(text)}}, nil)
+	}
+	tokens := s.Tokenizer.Encode(text)
+
+	// offsets[i] 为第 i 个 token 在原文中的起始字节偏移
+	offsets := make([]int, len(tokens)+1)
+	for i, t := range tokens {
+		offsets[i+1] = offsets[i] + len(s.Tokenizer.Decode([]int{t}))
+	}
+
+	step := s.Size - s.Overlap
+	if step <= 0 {
+		step = s.Size
+	}
+
+	var chunks []Chunk
+	for i := 0; i < len(tokens); i += step {
+		end := min(i+s.Size, len(tokens))
+		// 多字节字符可能被拆到相邻的 token 中，边界向后对齐到字符起始，使相邻窗口不重复
+		start, stop := alignRune(text, offsets[i]), alignRune(text, offsets[end])
+		if start < stop {
+			chunks = append(chunks, Chunk{Text: text[start:stop], Source: source, Start: start, End: stop})
+		}
+		if end == len(tokens) {
+			break
+		}
+	}
+	return chunks
+}
+
+// alignRune 将偏移向后移动到字符起始位置
+func alignRune(text string, offset int) int {
+	for offset < len(text) && !utf8.RuneStart(text[offset]) {
+		offset++
+	}
+	return min(offset, len(text))
+}
@@ -0,0 +1,239 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrTopicClosed 向已关闭的主题发布消息
+var ErrTopicClosed = errors.New("stream: topic closed")
+
+// SlowPolicy 订阅者队列已满时发布者的处理方式
+type SlowPolicy int
+
+const (
+	PolicyBlock      SlowPolicy = iota // 等待订阅者跟上或离开
+	PolicyDrop                         // 只对该订阅者丢弃这条消息
+	PolicyDisconnect                   // 送完已排队的消息后断开该订阅者
+)
+
+type BrokerOptions struct {
+	Buffer      int        // 每个订阅者的队列长度，默认 64，后加入的订阅者另加历史消息数
+	HistorySize int        // 为后加入的订阅者保留的消息数，0 表示全部保留
+	Policy      SlowPolicy // 订阅者队列已满时的处理方式
+}
+
+// Broker 将每个主题单个生产者的消息分发给任意多个订阅者。后加入的订阅者先收到历史消息再接收新消息，
+// 各自通过取消 ctx 离开。订阅得到的通道可直接交给 SSEStream.Stream
+type Broker struct {
+	options BrokerOptions
+
+	mu     sync.Mutex
+	topics map[string]*topic
+}
+
+type topic struct {
+	mu      sync.Mutex
+	history [][]byte
+	subs    map[*subscriber]struct{}
+	closed  bool
+}
+
+type subscriber struct {
+	queue chan []byte   // 从不关闭，发布者在锁外写入
+	end   chan struct{} // 主题关闭或被断开时关闭，送完已排队的消息后退出
+	done  chan struct{} // 订阅者退出时关闭，释放阻塞的发布者
+}
+
+func NewBroker(options BrokerOptions) *Broker {
+	if options.Buffer <= 0 {
+		options.Buffer = 64
+	}
+	return &Broker{options: options, topics: make(map[string]*topic)}
+}
+
+// lockTopic 返回已加锁的主题，不存在时创建，使订阅者可以先于生产者加入
+func (b *Broker) lockTopic(name string) *topic {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t, ok := b.topics[name]
+	if !ok {
+		t = &topic{subs: make(map[*subscriber]struct{})}
+		b.topics[name] = t
+	}
+	t.mu.Lock()
+	return t
+}
+
+// release 在主题既无订阅者也无历史时将其删除，避免任意主题名堆积。调用方需持有 b.mu 和 t.mu
+func (b *Broker) release(name string, t *topic) {
+	if len(t.subs) == 0 && len(t.history) == 0 && b.topics[name] == t {
+		delete(b.topics, name)
+	}
+}
+
+// Publish 将 data 追加到主题历史并按 SlowPolicy 发给每个订阅者。发送在主题锁外进行，
+// 阻塞的发布者不会卡住 Subscribe 和 Close；每个主题只应有一个生产者
+func (b *Broker) Publish(name string, data []byte) error {
+	t := b.lockTopic(name)
+	if t.closed {
+		t.mu.Unlock()
+		return ErrTopicClosed
+	}
+
+	t.history = append(t.history, data)
+	if n := b.options.HistorySize; n > 0 && len(t.history) > n {
+		t.history = append(t.history[:0:0], t.history[len(t.history)-n:]...)
+	}
+	subs := make([]*subscriber, 0, len(t.subs))
+	for sub := range t.subs {
+		subs = append(subs, sub)
+	}
+	t.mu.Unlock()
+
+	for _, sub := range subs {
+		select {
+		case sub.queue <- data:
+			continue
+		case <-sub.done:
+			continue
+		default:
+		}
+
+		switch b.options.Policy {
+		case PolicyDrop:
+		case PolicyDisconnect:
+			t.mu.Lock()
+			t.disconnect(sub)
+			t.mu.Unlock()
+		default:
+			select {
+			case sub.queue <- data:
+			case <-sub.done:
+			}
+		}
+	}
+	return nil
+}
+
+// Pump 发布 src 中的所有数据，src 关闭后关闭主题，需在单独的 goroutine 中运行
+func (b *Broker) Pump(name string, src <-chan []byte) {
+	for data := range src {
+		_ = b.Publish(name, data)
+	}
+	b.Close(name)
+}
+
+// Close 关闭主题，订阅者收完已排队的消息后通道关闭，之后的订阅者只收到历史消息
+func (b *Broker) Close(name string) {
+	t := b.lockTopic(name)
+	defer t.mu.Unlock()
+	if t.closed {
+		return
+	}
+	t.closed = true
+	for sub := range t.subs {
+		t.disconnect(sub)
+	}
+}
+
+// Remove 关闭主题并丢弃历史
+func (b *Broker) Remove(name string) {
+	b.Close(name)
+	b.mu.Lock()
+	delete(b.topics, name)
+	b.mu.Unlock()
+}
+
+// Subscribe 加入主题，返回的通道先输出历史再输出新消息，主题关闭、因过慢被断开或 ctx 结束时关闭
+func (b *Broker) Subscribe(ctx context.Context, name string) <-chan []byte {
+	t := b.lockTopic(name)
+	history := append([][]byte(nil), t.history...)
+	// 回放历史期间没人读取队列，队列按历史长度加大，避免 SlowPolicy 误伤正常的后加入者
+	sub := &subscriber{
+		queue: make(chan []byte, b.options.Buffer+len(history)),
+		end:   make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	if t.closed {
+		close(sub.end)
+	} else {
+		t.subs[sub] = struct{}{}
+	}
+	t.mu.Unlock()
+
+	out := make(chan []byte)
+	go func() {
+		defer close(out)
+		defer b.unsubscribe(name, t, sub)
+
+		send := func(data []byte) bool {
+			select {
+			case <-ctx.Done():
+				return false
+			case out <- data:
+				return true
+			}
+		}
+		for _, data := range history {
+			if !send(data) {
+				return
+			}
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case data := <-sub.queue:
+				if !send(data) {
+					return
+				}
+			case <-sub.end:
+				for {
+					select {
+					case data := <-sub.queue:
+						if !send(data) {
+							return
+						}
+					default:
+						return
+					}
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// Subscribers 返回主题当前的订阅者数量
+func (b *Broker) Subscribers(name string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t, ok := b.topics[name]
+	if !ok {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.subs)
+}
+
+// disconnect 移除订阅者并通知其退出，调用方需持有 t.mu
+func (t *topic) disconnect(sub *subscriber) {
+	if _, ok := t.subs[sub]; ok {
+		delete(t.subs, sub)
+		close(sub.end)
+	}
+}
+
+func (b *Broker) unsubscribe(name string, t *topic, sub *subscriber) {
+	// 先释放阻塞在该订阅者上的发布者
+	close(sub.done)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.subs, sub)
+	b.release(name, t)
+}
@@ -0,0 +1,252 @@
+package stream
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func collectBytes(ch <-chan []byte) []string {
+	var got []string
+	for data := range ch {
+		got = append(got, string(data))
+	}
+	return got
+}
+
+func publishAll(t *testing.T, b *Broker, topic string, msgs ...string) {
+	t.Helper()
+	for _, msg := range msgs {
+		if err := b.Publish(topic, []byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBroker_HistoryAndLateJoin(t *testing.T) {
+	b := NewBroker(BrokerOptions{})
+	ctx := context.Background()
+
+	early := b.Subscribe(ctx, "chat")
+	publishAll(t, b, "chat", "a", "b")
+	late := b.Subscribe(ctx, "chat")
+	publishAll(t, b, "chat", "c")
+	b.Close("chat")
+
+	if err := b.Publish("chat", []byte("d")); err != ErrTopicClosed {
+		t.Errorf("Expected ErrTopicClosed, got %v", err)
+	}
+	for name, ch := range map[string]<-chan []byte{"early": early, "late": late, "after close": b.Subscribe(ctx, "chat")} {
+		if got := strings.Join(collectBytes(ch), ","); got != "a,b,c" {
+			t.Errorf("%s subscriber: expected a,b,c, got %s", name, got)
+		}
+	}
+
+	b.Remove("chat")
+	fresh := b.Subscribe(ctx, "chat")
+	b.Close("chat")
+	if got := collectBytes(fresh); len(got) != 0 {
+		t.Errorf("Expected no history after Remove, got %v", got)
+	}
+}
+
+func TestBroker_HistorySize(t *testing.T) {
+	b := NewBroker(BrokerOptions{HistorySize: 2})
+	publishAll(t, b, "chat", "a", "b", "c")
+	b.Close("chat")
+	if got := strings.Join(collectBytes(b.Subscribe(context.Background(), "chat")), ","); got != "b,c" {
+		t.Errorf("Expected b,c, got %s", got)
+	}
+}
+
+func TestBroker_LeaveIndependently(t *testing.T) {
+	b := NewBroker(BrokerOptions{})
+	ctx, cancel := context.WithCancel(context.Background())
+	leaving := b.Subscribe(ctx, "chat")
+	staying := b.Subscribe(context.Background(), "chat")
+
+	publishAll(t, b, "chat", "a")
+	if got := string(<-leaving); got != "a" {
+		t.Fatalf("Expected a, got %q", got)
+	}
+	cancel()
+	collectBytes(leaving)
+	waitFor(t, func() bool { return b.Subscribers("chat") == 1 })
+
+	publishAll(t, b, "chat", "b")
+	b.Close("chat")
+	if got := strings.Join(collectBytes(staying), ","); got != "a,b" {
+		t.Errorf("Expected a,b, got %s", got)
+	}
+}
+
+func TestBroker_SlowPolicies(t *testing.T) {
+	msgs := []string{"1", "2", "3", "4", "5", "6"}
+
+	t.Run("Drop", func(t *testing.T) {
+		b := NewBroker(BrokerOptions{Buffer: 1, Policy: PolicyDrop})
+		sub := b.Subscribe(context.Background(), "chat")
+		publishAll(t, b, "chat", msgs...)
+		b.Close("chat")
+
+		got := collectBytes(sub)
+		if len(got) == 0 || len(got) >= len(msgs) || got[0] != "1" {
+			t.Errorf("Expected some messages dropped, got %v", got)
+		}
+	})
+
+	t.Run("Disconnect", func(t *testing.T) {
+		b := NewBroker(BrokerOptions{Buffer: 1, Policy: PolicyDisconnect})
+		slow := b.Subscribe(context.Background(), "chat")
+		publishAll(t, b, "chat", msgs...)
+
+		// 主题未关闭，但慢订阅者已被断开
+		got := collectBytes(slow)
+		if len(got) == 0 || len(got) >= len(msgs) || b.Subscribers("chat") != 0 {
+			t.Errorf("Expected slow subscriber to be disconnected, got %v", got)
+		}
+		b.Close("chat")
+	})
+
+	t.Run("Block", func(t *testing.T) {
+		b := NewBroker(BrokerOptions{Buffer: 1, Policy: PolicyBlock})
+		sub := b.Subscribe(context.Background(), "chat")
+
+		published := make(chan struct{})
+		go func() {
+			publishAll(t, b, "chat", msgs...)
+			b.Close("chat")
+			close(published)
+		}()
+
+		select {
+		case <-published:
+			t.Fatal("Expected publisher to block on the slow subscriber")
+		case <-time.After(20 * time.Millisecond):
+		}
+		if got := strings.Join(collectBytes(sub), ","); got != strings.Join(msgs, ",") {
+			t.Errorf("Expected every message, got %s", got)
+		}
+		<-published
+	})
+
+	t.Run("BlockReleasedByLeave", func(t *testing.T) {
+		b := NewBroker(BrokerOptions{Buffer: 1, Policy: PolicyBlock})
+		ctx, cancel := context.WithCancel(context.Background())
+		b.Subscribe(ctx, "chat")
+
+		published := make(chan struct{})
+		go func() {
+			publishAll(t, b, "chat", msgs...)
+			close(published)
+		}()
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+		<-published
+	})
+}
+
+func TestBroker_LateJoinerKeepsLiveMessages(t *testing.T) {
+	for _, policy := range []SlowPolicy{PolicyDrop, PolicyDisconnect} {
+		b := NewBroker(BrokerOptions{Buffer: 1, Policy: policy})
+		publishAll(t, b, "chat", "1", "2", "3", "4")
+
+		// 订阅者还在接收历史时发布的消息不应被丢弃，也不应导致断开
+		sub := b.Subscribe(context.Background(), "chat")
+		publishAll(t, b, "chat", "5", "6")
+		b.Close("chat")
+
+		if got := strings.Join(collectBytes(sub), ","); got != "1,2,3,4,5,6" {
+			t.Errorf("Policy %d: expected every message, got %s", policy, got)
+		}
+	}
+}
+
+func TestBroker_SSEFanOut(t *testing.T) {
+	b := NewBroker(BrokerOptions{})
+	src := make(chan []byte)
+	go b.Pump("answer", src)
+
+	viewer := b.Subscribe(context.Background(), "answer")
+	src <- []byte("Hel")
+	late := b.Subscribe(context.Background(), "answer")
+	src <- []byte("lo")
+	close(src)
+
+	for _, sub := range []<-chan []byte{viewer, late} {
+		w := httptest.NewRecorder()
+		sse := &SSEStream{Processor: SSEDataProcessor}
+		if err := sse.Stream(context.Background(), w, sub); err != nil {
+			t.Fatal(err)
+		}
+		if w.Body.String() != "data: Hel\n\ndata: lo\n\n" {
+			t.Errorf("Unexpected body %q", w.Body.String())
+		}
+	}
+}
+
+func TestBroker_BlockedPublisherDoesNotStall(t *testing.T) {
+	b := NewBroker(BrokerOptions{Buffer: 1, Policy: PolicyBlock})
+	ctx, cancel := context.WithCancel(context.Background())
+	b.Subscribe(ctx, "chat")
+
+	published := make(chan struct{})
+	go func() {
+		publishAll(t, b, "chat", "1", "2", "3", "4", "5", "6")
+		close(published)
+	}()
+
+	// 发布者阻塞在慢订阅者上时，其他订阅者仍可加入和离开
+	other, leave := context.WithCancel(context.Background())
+	sub := b.Subscribe(other, "chat")
+	if got := string(<-sub); got != "1" {
+		t.Errorf("Expected history to start with 1, got %q", got)
+	}
+	leave()
+	collectBytes(sub)
+	b.Subscribers("chat")
+
+	cancel()
+	<-published
+	b.Close("chat")
+}
+
+func TestBroker_ReleasesEmptyTopics(t *testing.T) {
+	b := NewBroker(BrokerOptions{})
+	topics := func() int {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return len(b.topics)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	idle := b.Subscribe(ctx, "unknown")
+	cancel()
+	collectBytes(idle)
+	if n := topics(); n != 0 {
+		t.Errorf("Expected topic without history to be released, got %d topics", n)
+	}
+
+	publishAll(t, b, "chat", "a")
+	ctx, cancel = context.WithCancel(context.Background())
+	sub := b.Subscribe(ctx, "chat")
+	<-sub
+	cancel()
+	collectBytes(sub)
+	if n := topics(); n != 1 {
+		t.Errorf("Expected topic with history to be kept, got %d topics", n)
+	}
+}
@@ -0,0 +1,196 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+)
+
+// DefaultRetry 服务端未指定 retry 时的重连间隔
+const DefaultRetry = 3 * time.Second
+
+// SSEClient 读取 text/event-stream 接口，断线后按 EventSource 的规则重连：
+//
+//   - 服务端的 retry 字段替换重连间隔
+//   - 重连时以 Last-Event-ID 带上最后收到的事件 ID
+//   - 204 No Content 正常结束，其他非 200 状态或错误的 Content-Type 视为失败
+//
+// 同一时间只能有一个连接
+type SSEClient struct {
+	URL    string
+	Method string // 默认 GET
+	Body   []byte // 每次重连都重新发送
+	Header http.Header
+	Client *http.Client // 默认 http.DefaultClient
+
+	Retry        time.Duration // 初始重连间隔，默认 DefaultRetry
+	MaxRetries   int           // 连续重连失败多少次后放弃，0 表示不限制
+	MaxEventSize int           // 见 Decoder.MaxEventSize
+	LastEventID  string        // 首次请求时发送，用于接续之前的流
+
+	err error
+}
+
+// Connect 同步发起首次请求以便直接返回配置和 HTTP 错误，之后在返回的通道上持续输出事件，
+// 直到 ctx 结束、服务端返回 204 或重连失败。通道关闭后由 Err 给出原因
+func (c *SSEClient) Connect(ctx context.Context) (<-chan SSEMessage, error) {
+	c.err = nil
+	lastID := c.LastEventID
+	resp, _, err := c.open(ctx, lastID)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan SSEMessage)
+	go c.run(ctx, resp, lastID, events)
+	return events, nil
+}
+
+// Err 返回流结束的原因，服务端以 204 结束时为 nil。只能在事件通道关闭后调用
+func (c *SSEClient) Err() error {
+	return c.err
+}
+
+func (c *SSEClient) run(ctx context.Context, resp *http.Response, lastID string, events chan<- SSEMessage) {
+	defer close(events)
+
+	retry := c.Retry
+	if retry <= 0 {
+		retry = DefaultRetry
+	}
+
+	failures := 0
+	for {
+		if resp == nil {
+			// 204 No Content：服务端要求不再重连
+			return
+		}
+
+		decoder := NewDecoder(resp.Body)
+		decoder.MaxEventSize = c.MaxEventSize
+		// 新连接中没有 id 字段的事件沿用之前的 ID，否则会把 lastID 清空
+		decoder.SetLastEventID(lastID)
+		received, err := c.read(ctx, decoder, &lastID, events)
+		resp.Body.Close()
+		if ms, ok := decoder.Retry(); ok {
+			retry = time.Duration(ms) * time.Millisecond
+		}
+
+		var tooLarge *EventTooLargeError
+		switch {
+		case ctx.Err() != nil:
+			c.err = ctx.Err()
+			return
+		case errors.As(err, &tooLarge):
+			c.err = err
+			return
+		case received:
+			failures = 0
+		}
+
+		for {
+			if c.MaxRetries > 0 && failures >= c.MaxRetries {
+				c.err = fmt.Errorf("sse: giving up after %d reconnects: %w", failures, err)
+				return
+			}
+			failures++
+
+			timer := time.NewTimer(retry)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				c.err = ctx.Err()
+				return
+			case <-timer.C:
+			}
+
+			var retryable bool
+			resp, retryable, err = c.open(ctx, lastID)
+			if err == nil {
+				break
+			}
+			if !retryable || ctx.Err() != nil {
+				c.err = err
+				return
+			}
+		}
+	}
+}
+
+// read 转发事件直到连接结束，返回是否收到过事件
+func (c *SSEClient) read(ctx context.Context, decoder *Decoder, lastID *string, events chan<- SSEMessage) (bool, error) {
+	received := false
+	for {
+		msg, err := decoder.Decode()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return received, err
+		}
+		received = true
+		*lastID = msg.ID
+
+		select {
+		case <-ctx.Done():
+			return received, ctx.Err()
+		case events <- msg:
+		}
+	}
+}
+
+// open 发送一次请求，响应和错误都为 nil 表示 204 No Content，retryable 表示是否为可重试的网络错误
+func (c *SSEClient) open(ctx context.Context, lastID string) (*http.Response, bool, error) {
+	method := c.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	var body io.Reader
+	if c.Body != nil {
+		body = bytes.NewReader(c.Body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.URL, body)
+	if err != nil {
+		return nil, false, err
+	}
+	for k, v := range c.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Cache-Control", "no-cache")
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, true, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNoContent:
+		resp.Body.Close()
+		return nil, false, nil
+	default:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, false, fmt.Errorf("sse: unexpected status %s: %s", resp.Status, msg)
+	}
+
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/event-stream" {
+		resp.Body.Close()
+		return nil, false, fmt.Errorf("sse: unexpected Content-Type %q", resp.Header.Get("Content-Type"))
+	}
+	return resp, false, nil
+}
@@ -0,0 +1,194 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func collect(events <-chan SSEMessage) []SSEMessage {
+	var msgs []SSEMessage
+	for msg := range events {
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+func TestSSEClient_Reconnect(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		lastIDs []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		lastIDs = append(lastIDs, r.Header.Get("Last-Event-ID"))
+		n := len(lastIDs)
+		mu.Unlock()
+
+		if r.Header.Get("Authorization") != "Bearer key" || r.Header.Get("Accept") != "text/event-stream" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+		switch n {
+		case 1:
+			fmt.Fprint(w, "retry: 10\nid: 1\ndata: a\n\nid: 2\ndata: partial")
+		case 2:
+			fmt.Fprint(w, "id: 3\nevent: update\ndata: b\n\n")
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	client := &SSEClient{URL: server.URL, Header: http.Header{"Authorization": {"Bearer key"}}, Retry: time.Minute}
+	events, err := client.Connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// retry: 10 替换一分钟的初始间隔，否则测试会超时
+	msgs := collect(events)
+	if client.Err() != nil {
+		t.Fatal(client.Err())
+	}
+	expected := []SSEMessage{{ID: "1", Data: "a", Retry: intPtr(10)}, {ID: "3", Event: "update", Data: "b"}}
+	if !reflect.DeepEqual(msgs, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, msgs)
+	}
+
+	// 未完成的事件 2 被丢弃，重连时只带上已派发的 ID
+	if strings.Join(lastIDs, ",") != ",1,3" {
+		t.Errorf("Unexpected Last-Event-ID headers %q", lastIDs)
+	}
+}
+
+func TestSSEClient_ReconnectWithoutID(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		lastIDs []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		lastIDs = append(lastIDs, r.Header.Get("Last-Event-ID"))
+		n := len(lastIDs)
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		switch n {
+		case 1:
+			fmt.Fprint(w, "retry: 10\nid: 1\ndata: a\n\n")
+		case 2:
+			// 第二个连接的事件没有 id 字段，断开后仍应带上 ID 1
+			fmt.Fprint(w, "data: b\n\n")
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	client := &SSEClient{URL: server.URL}
+	events, err := client.Connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := collect(events)
+	if client.Err() != nil {
+		t.Fatal(client.Err())
+	}
+	expected := []SSEMessage{{ID: "1", Data: "a", Retry: intPtr(10)}, {ID: "1", Data: "b"}}
+	if !reflect.DeepEqual(msgs, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, msgs)
+	}
+	if strings.Join(lastIDs, ",") != ",1,1" {
+		t.Errorf("Unexpected Last-Event-ID headers %q", lastIDs)
+	}
+}
+
+func TestSSEClient_ConnectErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		err     string
+	}{
+		{
+			name: "Status",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "nope", http.StatusForbidden)
+			},
+			err: "unexpected status 403 Forbidden: nope",
+		},
+		{
+			name: "ContentType",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprint(w, "{}")
+			},
+			err: `unexpected Content-Type "application/json"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+
+			_, err := (&SSEClient{URL: server.URL}).Connect(context.Background())
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Expected error %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestSSEClient_MaxRetries(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "text/event-stream")
+	}))
+	defer server.Close()
+
+	client := &SSEClient{URL: server.URL, Retry: time.Millisecond, MaxRetries: 2}
+	events, err := client.Connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	collect(events)
+	if client.Err() == nil || !strings.Contains(client.Err().Error(), "giving up after 2 reconnects") || calls.Load() != 3 {
+		t.Errorf("Expected to give up after 2 reconnects, got %v with %d calls", client.Err(), calls.Load())
+	}
+}
+
+func TestSSEClient_Cancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: first\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := &SSEClient{URL: server.URL}
+	events, err := client.Connect(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg := <-events; msg.Data != "first" {
+		t.Fatalf("Expected first event, got %+v", msg)
+	}
+	cancel()
+	collect(events)
+	if !errors.Is(client.Err(), context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", client.Err())
+	}
+}
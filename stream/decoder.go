@@ -0,0 +1,189 @@
+package stream
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DefaultMaxEventSize Decoder.MaxEventSize 为 0 时单个事件的大小上限
+const DefaultMaxEventSize = 16 << 20
+
+// EventTooLargeError 单个事件或单行超出大小上限，之后的 Decode 都返回该错误
+type EventTooLargeError struct {
+	Limit int
+}
+
+func (e *EventTooLargeError) Error() string {
+	return fmt.Sprintf("sse: event exceeds max size of %d bytes", e.Limit)
+}
+
+// Decoder 按 WHATWG 规范解析 text/event-stream
+//
+// 行尾可以是 CRLF、LF 或 CR，多个 data 字段以换行连接，空行分发事件（没有 data 的块不分发），
+// 事件 ID 在被新的 id 字段替换前一直有效。单个事件的大小受 MaxEventSize 限制
+type Decoder struct {
+	MaxEventSize int // 单个事件最多缓冲的字节数，为 0 时使用 DefaultMaxEventSize
+
+	r       *bufio.Reader
+	started bool
+	skipLF  bool // 上一行以 CR 结尾，跳过紧随的 LF
+	err     error
+
+	lastID string
+	retry  *int
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// Decode 返回下一个事件，默认的 message 类型 Event 为空。流结束时返回 io.EOF，丢弃不完整的事件
+func (d *Decoder) Decode() (SSEMessage, error) {
+	var (
+		data    strings.Builder
+		hasData bool
+		event   string
+		retry   *int
+	)
+
+	if d.err != nil {
+		return SSEMessage{}, d.err
+	}
+
+	limit := d.MaxEventSize
+	if limit <= 0 {
+		limit = DefaultMaxEventSize
+	}
+
+	for {
+		raw, err := d.readLine(limit - data.Len())
+		if err != nil {
+			if err == errLineTooLong {
+				err = &EventTooLargeError{Limit: limit}
+			}
+			// 流末尾不完整的事件直接丢弃
+			d.err = err
+			return SSEMessage{}, err
+		}
+
+		line := string(raw)
+		if !d.started {
+			d.started = true
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+
+		if line == "" {
+			if !hasData {
+				// 没有可分发的数据，重置事件类型
+				event, retry = "", nil
+				continue
+			}
+			msg := SSEMessage{ID: d.lastID, Event: event, Data: strings.TrimSuffix(data.String(), "\n"), Retry: retry}
+			return msg, nil
+		}
+
+		if line[0] == ':' {
+			continue
+		}
+
+		field, value, found := strings.Cut(line, ":")
+		if found {
+			value = strings.TrimPrefix(value, " ")
+		}
+
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				d.lastID = value
+			}
+		case "retry":
+			if ms, ok := parseRetry(value); ok {
+				d.retry = &ms
+				retry = &ms
+			}
+		}
+	}
+}
+
+// LastEventID 返回当前的事件 ID
+func (d *Decoder) LastEventID() string {
+	return d.lastID
+}
+
+// SetLastEventID 设置初始的事件 ID，重连时用于接续上一个连接的 ID
+func (d *Decoder) SetLastEventID(id string) {
+	d.lastID = id
+}
+
+// Retry 返回服务端最近一次指定的重连间隔（毫秒），包括未分发的块中的 retry 字段
+func (d *Decoder) Retry() (int, bool) {
+	if d.retry == nil {
+		return 0, false
+	}
+	return *d.retry, true
+}
+
+// parseRetry 按规范只接受 ASCII 数字
+func parseRetry(value string) (int, bool) {
+	if value == "" {
+		return 0, false
+	}
+	for i := 0; i < len(value); i++ {
+		if value[i] < '0' || value[i] > '9' {
+			return 0, false
+		}
+	}
+	ms, err := strconv.Atoi(value)
+	return ms, err == nil
+}
+
+var errLineTooLong = errors.New("sse: line exceeds budget")
+
+// readLine 读取一行（含 EOF 前没有行尾的最后一行），超过 budget 字节时返回 errLineTooLong 且不再缓冲
+func (d *Decoder) readLine(budget int) ([]byte, error) {
+	if d.skipLF {
+		d.skipLF = false
+		if b, err := d.r.Peek(1); err == nil && b[0] == '\n' {
+			_, _ = d.r.Discard(1)
+		}
+	}
+
+	var line []byte
+	for {
+		if _, err := d.r.Peek(1); err != nil {
+			if err == io.EOF && len(line) > 0 {
+				return line, nil
+			}
+			return nil, err
+		}
+
+		buf, _ := d.r.Peek(d.r.Buffered())
+		i := bytes.IndexAny(buf, "\r\n")
+		n := len(buf)
+		if i >= 0 {
+			n = i
+		}
+		if len(line)+n > budget {
+			return nil, errLineTooLong
+		}
+		line = append(line, buf[:n]...)
+
+		if i >= 0 {
+			d.skipLF = buf[i] == '\r'
+			_, _ = d.r.Discard(i + 1)
+			return line, nil
+		}
+		_, _ = d.r.Discard(n)
+	}
+}
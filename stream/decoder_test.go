@@ -0,0 +1,188 @@
+package stream
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func decodeAll(t *testing.T, r io.Reader) []SSEMessage {
+	t.Helper()
+	var msgs []SSEMessage
+	dec := NewDecoder(r)
+	for {
+		msg, err := dec.Decode()
+		if errors.Is(err, io.EOF) {
+			return msgs
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, msg)
+	}
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func TestDecoder_Decode(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []SSEMessage
+	}{
+		{
+			name:     "DataWithSpace",
+			input:    "data: hello\n\n",
+			expected: []SSEMessage{{Data: "hello"}},
+		},
+		{
+			name:     "DataWithoutSpace",
+			input:    "data:{\"a\":1}\n\n",
+			expected: []SSEMessage{{Data: `{"a":1}`}},
+		},
+		{
+			name:     "OnlyFirstSpaceStripped",
+			input:    "data:  two spaces\n\n",
+			expected: []SSEMessage{{Data: " two spaces"}},
+		},
+		{
+			name:     "MultiLineData",
+			input:    "data: line 1\ndata: line 2\ndata\n\n",
+			expected: []SSEMessage{{Data: "line 1\nline 2\n"}},
+		},
+		{
+			name:     "NamedEventAndID",
+			input:    "event: delta\nid: 7\ndata: x\n\ndata: y\n\n",
+			expected: []SSEMessage{{ID: "7", Event: "delta", Data: "x"}, {ID: "7", Data: "y"}},
+		},
+		{
+			name:     "EmptyIDResetsLastID",
+			input:    "id: 1\ndata: a\n\nid\ndata: b\n\n",
+			expected: []SSEMessage{{ID: "1", Data: "a"}, {Data: "b"}},
+		},
+		{
+			name:     "IDWithNULIgnored",
+			input:    "id: 1\ndata: a\n\nid: 2\x003\ndata: b\n\n",
+			expected: []SSEMessage{{ID: "1", Data: "a"}, {ID: "1", Data: "b"}},
+		},
+		{
+			name:     "Comments",
+			input:    ": keep-alive\ndata: a\n:another\n\n",
+			expected: []SSEMessage{{Data: "a"}},
+		},
+		{
+			name:     "BlockWithoutDataNotDispatched",
+			input:    "event: ping\n\ndata: a\n\n",
+			expected: []SSEMessage{{Data: "a"}},
+		},
+		{
+			name:     "Retry",
+			input:    "retry: 3000\ndata: a\n\nretry: 1s\ndata: b\n\n",
+			expected: []SSEMessage{{Data: "a", Retry: intPtr(3000)}, {Data: "b"}},
+		},
+		{
+			name:     "UnknownFieldsIgnored",
+			input:    "foo: bar\ndata: a\n\n",
+			expected: []SSEMessage{{Data: "a"}},
+		},
+		{
+			name:     "CRLFAndCRLineEndings",
+			input:    "data: a\r\ndata: b\r\n\r\ndata: c\rdata: d\r\r",
+			expected: []SSEMessage{{Data: "a\nb"}, {Data: "c\nd"}},
+		},
+		{
+			name:     "LeadingBOM",
+			input:    "\ufeffdata: a\n\n",
+			expected: []SSEMessage{{Data: "a"}},
+		},
+		{
+			name:     "IncompleteEventDiscarded",
+			input:    "data: a\n\ndata: b\n",
+			expected: []SSEMessage{{Data: "a"}},
+		},
+		{
+			name:     "EmptyData",
+			input:    "data\n\ndata:\n\n",
+			expected: []SSEMessage{{Data: ""}, {Data: ""}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 逐字节读取，确保跨读取边界的 CRLF 也能正确处理
+			got := decodeAll(t, iotest.OneByteReader(strings.NewReader(tt.input)))
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestDecoder_RetryWithoutDispatch(t *testing.T) {
+	dec := NewDecoder(strings.NewReader("retry: 500\n\nid: 9\n\n"))
+	if _, err := dec.Decode(); !errors.Is(err, io.EOF) {
+		t.Fatalf("Expected io.EOF, got %v", err)
+	}
+	if ms, ok := dec.Retry(); !ok || ms != 500 {
+		t.Errorf("Expected retry 500, got %d %v", ms, ok)
+	}
+	if id := dec.LastEventID(); id != "9" {
+		t.Errorf("Expected last event ID 9, got %q", id)
+	}
+}
+
+func TestDecoder_RoundTrip(t *testing.T) {
+	data, err := SSEMessageProcessor([]byte(`{"ID":"1","Event":"update","Data":"a\nb","Retry":100}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := decodeAll(t, strings.NewReader(string(data)))
+	expected := []SSEMessage{{ID: "1", Event: "update", Data: "a\nb", Retry: intPtr(100)}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestDecoder_LongLines(t *testing.T) {
+	// 远超 bufio.Scanner 默认 64KB 限制的单行
+	payload := strings.Repeat("x", 1<<20)
+	got := decodeAll(t, strings.NewReader("data: "+payload+"\r\n\r\ndata: next\n\n"))
+	if len(got) != 2 || got[0].Data != payload || got[1].Data != "next" {
+		t.Fatalf("Expected long event followed by next, got %d messages", len(got))
+	}
+}
+
+func TestDecoder_MaxEventSize(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"SingleLine", "data: " + strings.Repeat("x", 200) + "\n\n"},
+		{"ManyLines", strings.Repeat("data: "+strings.Repeat("x", 30)+"\n", 10) + "\n"},
+		{"Unterminated", "data: " + strings.Repeat("x", 200)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec := NewDecoder(strings.NewReader("data: ok\n\n" + tt.input))
+			dec.MaxEventSize = 100
+
+			if msg, err := dec.Decode(); err != nil || msg.Data != "ok" {
+				t.Fatalf("Expected first event ok, got %+v %v", msg, err)
+			}
+
+			_, err := dec.Decode()
+			var tooLarge *EventTooLargeError
+			if !errors.As(err, &tooLarge) || tooLarge.Limit != 100 {
+				t.Fatalf("Expected EventTooLargeError with limit 100, got %v", err)
+			}
+			if _, again := dec.Decode(); again != err {
+				t.Errorf("Expected the error to be sticky, got %v", again)
+			}
+		})
+	}
+}
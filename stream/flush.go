@@ -0,0 +1,71 @@
+package stream
+
+import (
+	"bytes"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// FlushPolicy 决定缓冲的输出何时发给客户端，以延迟换取更少、更大的数据包。
+// 零值在每个事件后刷新，否则满足任一条件即刷新，流结束时总会刷新
+type FlushPolicy struct {
+	Interval time.Duration          // 待发送的输出最多等待多久
+	MaxBytes int                    // 待发送的字节数达到该值时刷新
+	Boundary func(data []byte) bool // 返回 true 的项之后立即刷新，如 SentenceBoundary；未设置 Interval 时输出可能一直等到下一个边界
+}
+
+// PerEvent 报告是否每个事件后都刷新
+func (p FlushPolicy) PerEvent() bool {
+	return p.Interval <= 0 && p.MaxBytes <= 0 && p.Boundary == nil
+}
+
+// Due 报告以 item 结尾的 pending 字节输出是否需要立即刷新，而不是等待 Interval
+func (p FlushPolicy) Due(pending int, item []byte) bool {
+	return p.PerEvent() ||
+		(p.MaxBytes > 0 && pending >= p.MaxBytes) ||
+		(p.Boundary != nil && p.Boundary(item))
+}
+
+// SentenceBoundary 报告 data 是否以中英文句末标点或换行结尾，忽略末尾的空白和右引号、右括号
+func SentenceBoundary(data []byte) bool {
+	if bytes.HasSuffix(data, []byte("\n")) {
+		return true
+	}
+	data = bytes.TrimRightFunc(data, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '"' || r == '\'' || r == '”' || r == '’' || r == '」' || r == '）' || r == ')'
+	})
+	r, _ := utf8.DecodeLastRune(data)
+	switch r {
+	case '.', '!', '?', ';', ':', '。', '！', '？', '；', '：', '…':
+		return true
+	}
+	return false
+}
+
+// flushTimer 按 FlushPolicy.Interval 延迟刷新
+type flushTimer struct {
+	interval time.Duration
+	timer    *time.Timer
+	C        <-chan time.Time
+}
+
+// start 启动定时器，已在运行或未启用时不做任何事
+func (t *flushTimer) start() {
+	if t.interval <= 0 || t.C != nil {
+		return
+	}
+	if t.timer == nil {
+		t.timer = time.NewTimer(t.interval)
+	} else {
+		t.timer.Reset(t.interval)
+	}
+	t.C = t.timer.C
+}
+
+func (t *flushTimer) stop() {
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+	t.C = nil
+}
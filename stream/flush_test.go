@@ -0,0 +1,102 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// flushRecorder 记录每两次 Flush 之间写出的内容
+type flushRecorder struct {
+	header  http.Header
+	buf     bytes.Buffer
+	flushes []string
+}
+
+func (w *flushRecorder) Header() http.Header {
+	if w.header == nil {
+		w.header = make(http.Header)
+	}
+	return w.header
+}
+
+func (w *flushRecorder) Write(data []byte) (int, error) {
+	return w.buf.Write(data)
+}
+
+func (w *flushRecorder) WriteHeader(statusCode int) {}
+
+func (w *flushRecorder) Flush() {
+	w.flushes = append(w.flushes, w.buf.String())
+	w.buf.Reset()
+}
+
+func TestSSEStream_FlushPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		sse      *SSEStream
+		items    []string
+		expected []string
+	}{
+		{"PerEvent", &SSEStream{}, []string{"a", "b", "c"}, []string{"a", "b", "c"}},
+		{"MaxBytes", &SSEStream{Flush: FlushPolicy{MaxBytes: 4}}, []string{"ab", "cd", "e", "f"}, []string{"abcd", "ef"}},
+		{
+			"Sentences",
+			&SSEStream{Processor: SSEDataProcessor, Flush: FlushPolicy{Boundary: SentenceBoundary}},
+			[]string{"Hel", "lo.", "Wor", "ld"},
+			[]string{"data: Hel\n\ndata: lo.\n\n", "data: Wor\n\ndata: ld\n\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &flushRecorder{}
+			if err := tt.sse.Stream(context.Background(), w, feed(tt.items...)); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(w.flushes, tt.expected) {
+				t.Errorf("Expected flushes %q, got %q", tt.expected, w.flushes)
+			}
+		})
+	}
+}
+
+func TestSSEStream_FlushInterval(t *testing.T) {
+	dataChan := make(chan []byte)
+	go func() {
+		defer close(dataChan)
+		dataChan <- []byte("a")
+		dataChan <- []byte("b")
+		time.Sleep(100 * time.Millisecond)
+		dataChan <- []byte("c")
+	}()
+
+	w := &flushRecorder{}
+	sse := &SSEStream{Flush: FlushPolicy{Interval: 30 * time.Millisecond}}
+	if err := sse.Stream(context.Background(), w, dataChan); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(w.flushes, []string{"ab", "c"}) {
+		t.Errorf("Expected flushes [ab c], got %q", w.flushes)
+	}
+}
+
+func TestSentenceBoundary(t *testing.T) {
+	tests := map[string]bool{
+		"Hello.":     true,
+		"Really?\" ": true,
+		"好的。":        true,
+		"真的吗？」":      true,
+		"line\n":     true,
+		"Hel":        false,
+		"3.14 is":    false,
+		"":           false,
+	}
+	for input, expected := range tests {
+		if got := SentenceBoundary([]byte(input)); got != expected {
+			t.Errorf("SentenceBoundary(%q) = %v, expected %v", input, got, expected)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// Streamer 将 dataChan 中的数据写入 w，直到通道关闭或 ctx 结束
+type Streamer interface {
+	Stream(ctx context.Context, w http.ResponseWriter, dataChan <-chan []byte) error
+}
+
+// NDJSONStream 每条数据输出为一行 JSON，经 Processor 处理后必须是合法 JSON，写出前压缩为单行
+type NDJSONStream struct {
+	Processor DataProcessor
+}
+
+func (s *NDJSONStream) Stream(ctx context.Context, w http.ResponseWriter, dataChan <-chan []byte) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return fmt.Errorf("streaming not supported")
+	}
+
+	w.Header().Set("Content-Type", MediaTypeNDJSON)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	var line bytes.Buffer
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case data, open := <-dataChan:
+			if !open {
+				return nil
+			}
+
+			line.Reset()
+			if err := compactItem(&line, s.Processor, data); err != nil {
+				return err
+			}
+			line.WriteByte('\n')
+			if _, err := w.Write(line.Bytes()); err != nil {
+				return err
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// JSONArrayStream 将所有数据输出为一个 JSON 数组，默认逐个元素写出。
+// Buffered 时等 dataChan 关闭后带 Content-Length 一次性写出，适合不能增量读取的客户端
+type JSONArrayStream struct {
+	Processor DataProcessor
+	Buffered  bool
+}
+
+func (s *JSONArrayStream) Stream(ctx context.Context, w http.ResponseWriter, dataChan <-chan []byte) error {
+	var flusher http.Flusher
+	if !s.Buffered {
+		var ok bool
+		if flusher, ok = w.(http.Flusher); !ok {
+			return fmt.Errorf("streaming not supported")
+		}
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+	}
+	w.Header().Set("Content-Type", MediaTypeJSON)
+
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	first := true
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case data, open := <-dataChan:
+			if !open {
+				buf.WriteByte(']')
+				if s.Buffered {
+					w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+				}
+				_, err := w.Write(buf.Bytes())
+				return err
+			}
+
+			if !first {
+				buf.WriteByte(',')
+			}
+			first = false
+			if err := compactItem(&buf, s.Processor, data); err != nil {
+				return err
+			}
+			if s.Buffered {
+				continue
+			}
+			if _, err := w.Write(buf.Bytes()); err != nil {
+				return err
+			}
+			flusher.Flush()
+			buf.Reset()
+		}
+	}
+}
+
+// compactItem 用 processor 处理 data，并以压缩后的 JSON 追加到 buf
+func compactItem(buf *bytes.Buffer, processor DataProcessor, data []byte) error {
+	if processor != nil {
+		var err error
+		if data, err = processor(data); err != nil {
+			return err
+		}
+	}
+	if err := json.Compact(buf, data); err != nil {
+		return fmt.Errorf("invalid JSON item: %w", err)
+	}
+	return nil
+}
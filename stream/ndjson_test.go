@@ -0,0 +1,153 @@
+package stream
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+func feed(items ...string) chan []byte {
+	ch := make(chan []byte, len(items))
+	for _, item := range items {
+		ch <- []byte(item)
+	}
+	close(ch)
+	return ch
+}
+
+func TestNDJSONStream_Stream(t *testing.T) {
+	w := httptest.NewRecorder()
+	s := &NDJSONStream{}
+	if err := s.Stream(context.Background(), w, feed(`{"a": 1}`, "{\n  \"b\": [1, 2]\n}")); err != nil {
+		t.Fatal(err)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != MediaTypeNDJSON {
+		t.Errorf("Expected %s, got %q", MediaTypeNDJSON, ct)
+	}
+	if got := w.Body.String(); got != "{\"a\":1}\n{\"b\":[1,2]}\n" {
+		t.Errorf("Unexpected body %q", got)
+	}
+
+	w = httptest.NewRecorder()
+	if err := s.Stream(context.Background(), w, feed(`{"ok":true}`, "not json")); err == nil {
+		t.Error("Expected error for invalid JSON item")
+	}
+	if got := w.Body.String(); got != "{\"ok\":true}\n" {
+		t.Errorf("Expected only the valid line, got %q", got)
+	}
+}
+
+func TestJSONArrayStream_Stream(t *testing.T) {
+	tests := []struct {
+		name     string
+		buffered bool
+		items    []string
+		expected string
+	}{
+		{"Streamed", false, []string{`{"a":1}`, ` 2 `}, `[{"a":1},2]`},
+		{"Buffered", true, []string{`"x"`, `"y"`}, `["x","y"]`},
+		{"Empty", true, nil, `[]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s := &JSONArrayStream{Buffered: tt.buffered}
+			if err := s.Stream(context.Background(), w, feed(tt.items...)); err != nil {
+				t.Fatal(err)
+			}
+			if got := w.Body.String(); got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != MediaTypeJSON {
+				t.Errorf("Expected %s, got %q", MediaTypeJSON, ct)
+			}
+			if cl := w.Header().Get("Content-Length"); tt.buffered != (cl != "") {
+				t.Errorf("Unexpected Content-Length %q", cl)
+			}
+		})
+	}
+
+	// 缓冲模式在出错时不写任何内容
+	w := httptest.NewRecorder()
+	s := &JSONArrayStream{Buffered: true}
+	if err := s.Stream(context.Background(), w, feed(`1`, `{`)); err == nil || w.Body.Len() != 0 {
+		t.Errorf("Expected error with empty body, got %v %q", err, w.Body.String())
+	}
+}
+
+func TestNegotiateMediaType(t *testing.T) {
+	tests := []struct {
+		accept   string
+		expected string
+	}{
+		{"", MediaTypeSSE},
+		{"*/*", MediaTypeSSE},
+		{"application/x-ndjson", MediaTypeNDJSON},
+		{"text/event-stream, application/x-ndjson;q=0.5", MediaTypeSSE},
+		// JSON 数组只能通过 format=json 指定，application/json 仍按 SSE 处理
+		{"application/json", MediaTypeSSE},
+		{"application/*", MediaTypeSSE},
+		{"text/event-stream;q=0.5, application/json", MediaTypeSSE},
+		{"application/json;q=0.9, */*;q=0.1", MediaTypeSSE},
+		// 显式拒绝 SSE 时退回 NDJSON
+		{"*/*;q=0.5, text/event-stream;q=0", MediaTypeNDJSON},
+		{"application/json, text/event-stream;q=0", ""},
+		{"text/html", ""},
+		{"application/*;q=0", ""},
+	}
+	for _, tt := range tests {
+		if got := NegotiateMediaType(tt.accept); got != tt.expected {
+			t.Errorf("NegotiateMediaType(%q) = %q, expected %q", tt.accept, got, tt.expected)
+		}
+	}
+}
+
+func TestNegotiateStream(t *testing.T) {
+	wrap := func(data []byte) ([]byte, error) {
+		return append([]byte(`{"v":`), append(data, '}')...), nil
+	}
+	bodies := map[string]string{
+		MediaTypeSSE:    "data: {\"v\":1}\n\ndata: {\"v\":2}\n\n",
+		MediaTypeNDJSON: "{\"v\":1}\n{\"v\":2}\n",
+		MediaTypeJSON:   `[{"v":1},{"v":2}]`,
+	}
+	tests := []struct {
+		target   string
+		accept   string
+		expected string
+	}{
+		{"/", "", MediaTypeSSE},
+		{"/", "*/*", MediaTypeSSE},
+		// OpenAI SDK 的默认值不能改变流式格式
+		{"/", "application/json", MediaTypeSSE},
+		{"/", "application/*", MediaTypeSSE},
+		{"/", "application/x-ndjson", MediaTypeNDJSON},
+		{"/", "text/event-stream, application/x-ndjson;q=0.5", MediaTypeSSE},
+		{"/?format=ndjson", "application/json", MediaTypeNDJSON},
+		{"/?format=json", "", MediaTypeJSON},
+		{"/?format=sse", "application/x-ndjson", MediaTypeSSE},
+		{"/", "text/plain", ""},
+		{"/?format=xml", "", ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", tt.target, nil)
+		r.Header.Set("Accept", tt.accept)
+		s, err := NegotiateStream(r, wrap)
+		if tt.expected == "" {
+			if err != ErrNotAcceptable {
+				t.Errorf("%s %q: expected ErrNotAcceptable, got %v", tt.target, tt.accept, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		if err = s.Stream(context.Background(), w, feed("1", "2")); err != nil {
+			t.Fatal(err)
+		}
+		if w.Body.String() != bodies[tt.expected] || w.Header().Get("Content-Type") != tt.expected {
+			t.Errorf("%s %q: unexpected response %q %q", tt.target, tt.accept, w.Header().Get("Content-Type"), w.Body.String())
+		}
+	}
+}
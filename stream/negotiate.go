@@ -0,0 +1,118 @@
+package stream
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// NegotiateStream 支持的媒体类型
+const (
+	MediaTypeSSE    = "text/event-stream"
+	MediaTypeNDJSON = "application/x-ndjson"
+	MediaTypeJSON   = "application/json"
+)
+
+// ErrNotAcceptable Accept 头不接受任何支持的媒体类型
+var ErrNotAcceptable = errors.New("no acceptable media type")
+
+// FormatParam 查询参数，显式指定流式格式：sse、ndjson 或 json
+const FormatParam = "format"
+
+var streamFormats = map[string]string{
+	"sse":    MediaTypeSSE,
+	"ndjson": MediaTypeNDJSON,
+	"json":   MediaTypeJSON,
+}
+
+// NegotiateMediaType 按 Accept 头为流式响应选择 SSE 或 NDJSON，都不可接受时返回 ""。
+// 只有显式列出 NDJSON 且 q 值高于 SSE，或显式拒绝 SSE 时才用 NDJSON。OpenAI SDK 默认发送
+// Accept: application/json，因此它、通配符和空值都按 SSE 处理；缓冲的 JSON 数组只能通过 format=json 指定
+func NegotiateMediaType(accept string) string {
+	if strings.TrimSpace(accept) == "" {
+		return MediaTypeSSE
+	}
+	sse, sseSpecificity := acceptQuality(accept, MediaTypeSSE)
+	ndjson, ndjsonSpecificity := acceptQuality(accept, MediaTypeNDJSON)
+	switch {
+	case ndjsonSpecificity == 2 && ndjson > sse:
+		return MediaTypeNDJSON
+	case sse > 0:
+		return MediaTypeSSE
+	case sseSpecificity == 2:
+		if ndjson > 0 {
+			return MediaTypeNDJSON
+		}
+		return ""
+	}
+	if plain, _ := acceptQuality(accept, MediaTypeJSON); plain > 0 {
+		return MediaTypeSSE
+	}
+	return ""
+}
+
+// acceptQuality 返回 accept 中匹配 mediaType 的最具体范围的 q 值（不匹配为 0）及其具体程度：
+// 完全匹配为 2，type/* 为 1，*/* 为 0
+func acceptQuality(accept, mediaType string) (float64, int) {
+	typ, _, _ := strings.Cut(mediaType, "/")
+
+	q, specificity := 0.0, -1
+	for _, part := range strings.Split(accept, ",") {
+		rng, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		s := -1
+		switch {
+		case rng == mediaType:
+			s = 2
+		case rng == typ+"/*":
+			s = 1
+		case rng == "*/*":
+			s = 0
+		}
+		if s <= specificity {
+			continue
+		}
+
+		specificity, q = s, 1
+		if v, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+	}
+	return q, specificity
+}
+
+// NegotiateStream 为请求选择 SSEStream、NDJSONStream 或缓冲的 JSONArrayStream。Accept 头按 NegotiateMediaType
+// 选择 SSE 或 NDJSON，format 查询参数可指定任意一种，JSONArrayStream 只能这样指定。processor 可为空，在各格式自身的封装前执行
+func NegotiateStream(r *http.Request, processor DataProcessor) (Streamer, error) {
+	mediaType := NegotiateMediaType(r.Header.Get("Accept"))
+	if format := r.URL.Query().Get(FormatParam); format != "" {
+		mediaType = streamFormats[format]
+	}
+
+	switch mediaType {
+	case MediaTypeSSE:
+		sse := SSEDataProcessor
+		if processor != nil {
+			sse = func(data []byte) ([]byte, error) {
+				data, err := processor(data)
+				if err != nil {
+					return nil, err
+				}
+				return SSEDataProcessor(data)
+			}
+		}
+		return &SSEStream{Processor: sse}, nil
+	case MediaTypeNDJSON:
+		return &NDJSONStream{Processor: processor}, nil
+	case MediaTypeJSON:
+		return &JSONArrayStream{Processor: processor, Buffered: true}, nil
+	}
+	return nil, ErrNotAcceptable
+}
@@ -0,0 +1,319 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// pipeline 同一个源派生的所有阶段共享的状态
+type pipeline struct {
+	ctx  context.Context
+	done chan struct{}
+	once sync.Once
+	err  error
+}
+
+// stop 结束管道的所有阶段，记录第一个错误
+func (p *pipeline) stop(err error) {
+	p.once.Do(func() {
+		p.err = err
+		close(p.done)
+	})
+}
+
+// Stream 基于通道的泛型管道阶段，由 Map、Filter、Batch、Tee、Throttle 和 Encode 组合。
+// 每个阶段在独立的 goroutine 中运行，源耗尽、ctx 结束或任一阶段出错时停止
+type Stream[T any] struct {
+	p  *pipeline
+	ch <-chan T
+}
+
+// From 以 ch 为源创建管道，ctx 结束后各阶段不再读取，ch 的生产者也应随之停止
+func From[T any](ctx context.Context, ch <-chan T) *Stream[T] {
+	return &Stream[T]{p: &pipeline{ctx: ctx, done: make(chan struct{})}, ch: ch}
+}
+
+// FromSlice 以切片为源创建管道，按顺序输出
+func FromSlice[T any](ctx context.Context, items ...T) *Stream[T] {
+	ch := make(chan T, len(items))
+	for _, item := range items {
+		ch <- item
+	}
+	close(ch)
+	return From(ctx, ch)
+}
+
+// Generate 在独立的 goroutine 中运行 produce 作为管道的源。emit 在下游收到
+// 数据后返回 true，管道停止时返回 false；produce 返回的错误会终止管道
+func Generate[T any](ctx context.Context, produce func(emit func(T) bool) error) *Stream[T] {
+	p := &pipeline{ctx: ctx, done: make(chan struct{})}
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		if err := produce(func(v T) bool { return emit(p, out, v) }); err != nil {
+			p.stop(err)
+		}
+	}()
+	return &Stream[T]{p: p, ch: out}
+}
+
+// C 返回阶段的输出通道，阶段结束时关闭
+func (s *Stream[T]) C() <-chan T {
+	return s.ch
+}
+
+// Err 返回使管道停止的错误，被取消时返回 ctx 的错误，在 C 关闭后才有意义
+func (s *Stream[T]) Err() error {
+	select {
+	case <-s.p.done:
+		if s.p.err != nil {
+			return s.p.err
+		}
+	default:
+	}
+	return s.p.ctx.Err()
+}
+
+// Stop 结束管道的所有阶段，在读完输出前放弃时调用
+func (s *Stream[T]) Stop() {
+	s.p.stop(nil)
+}
+
+// Collect 读完流并返回切片
+func (s *Stream[T]) Collect() ([]T, error) {
+	var items []T
+	for item := range s.ch {
+		items = append(items, item)
+	}
+	return items, s.Err()
+}
+
+// next 读取下一项，输入耗尽或管道停止时返回 false
+func next[T any](p *pipeline, in <-chan T) (T, bool) {
+	var zero T
+	select {
+	case <-p.ctx.Done():
+		return zero, false
+	case <-p.done:
+		return zero, false
+	case v, ok := <-in:
+		return v, ok
+	}
+}
+
+// emit 向下游发送 v，管道先停止时返回 false
+func emit[T any](p *pipeline, out chan<- T, v T) bool {
+	select {
+	case <-p.ctx.Done():
+		return false
+	case <-p.done:
+		return false
+	case out <- v:
+		return true
+	}
+}
+
+// Map 对每一项执行 fn，fn 出错时停止管道
+func Map[T, U any](s *Stream[T], fn func(T) (U, error)) *Stream[U] {
+	out := make(chan U)
+	go func() {
+		defer close(out)
+		for {
+			v, ok := next(s.p, s.ch)
+			if !ok {
+				return
+			}
+			u, err := fn(v)
+			if err != nil {
+				s.p.stop(err)
+				return
+			}
+			if !emit(s.p, out, u) {
+				return
+			}
+		}
+	}()
+	return &Stream[U]{p: s.p, ch: out}
+}
+
+// Filter 只保留 keep 返回 true 的项
+func (s *Stream[T]) Filter(keep func(T) bool) *Stream[T] {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for {
+			v, ok := next(s.p, s.ch)
+			if !ok {
+				return
+			}
+			if keep(v) && !emit(s.p, out, v) {
+				return
+			}
+		}
+	}()
+	return &Stream[T]{p: s.p, ch: out}
+}
+
+// Batch 将最多 size 项合为一批，wait > 0 时批内第一项等待超过 wait 也会输出。size <= 0 时只按 wait 和输入结束分批
+func Batch[T any](s *Stream[T], size int, wait time.Duration) *Stream[[]T] {
+	out := make(chan []T)
+	go func() {
+		defer close(out)
+
+		var (
+			batch []T
+			timer *time.Timer
+			fire  <-chan time.Time
+		)
+		defer func() {
+			if timer != nil {
+				timer.Stop()
+			}
+		}()
+		flush := func() bool {
+			if timer != nil {
+				timer.Stop()
+				fire = nil
+			}
+			if len(batch) == 0 {
+				return true
+			}
+			b := batch
+			batch = nil
+			return emit(s.p, out, b)
+		}
+
+		for {
+			select {
+			case <-s.p.ctx.Done():
+				return
+			case <-s.p.done:
+				return
+			case <-fire:
+				if !flush() {
+					return
+				}
+			case v, ok := <-s.ch:
+				if !ok {
+					flush()
+					return
+				}
+				batch = append(batch, v)
+				if len(batch) == 1 && wait > 0 {
+					if timer == nil {
+						timer = time.NewTimer(wait)
+					} else {
+						timer.Reset(wait)
+					}
+					fire = timer.C
+				}
+				if size > 0 && len(batch) >= size && !flush() {
+					return
+				}
+			}
+		}
+	}()
+	return &Stream[[]T]{p: s.p, ch: out}
+}
+
+// Tee 将每一项依次复制到 n 个流，速度取决于最慢的消费者，每个输出都必须读完或停止管道
+func (s *Stream[T]) Tee(n int) []*Stream[T] {
+	outs := make([]chan T, n)
+	streams := make([]*Stream[T], n)
+	for i := range outs {
+		outs[i] = make(chan T)
+		streams[i] = &Stream[T]{p: s.p, ch: outs[i]}
+	}
+	go func() {
+		defer func() {
+			for _, out := range outs {
+				close(out)
+			}
+		}()
+		for {
+			v, ok := next(s.p, s.ch)
+			if !ok {
+				return
+			}
+			for _, out := range outs {
+				if !emit(s.p, out, v) {
+					return
+				}
+			}
+		}
+	}()
+	return streams
+}
+
+// Throttle 延迟输出使相邻两项至少间隔 interval，不丢弃任何项
+func (s *Stream[T]) Throttle(interval time.Duration) *Stream[T] {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		var last time.Time
+		for {
+			v, ok := next(s.p, s.ch)
+			if !ok {
+				return
+			}
+			if wait := interval - time.Since(last); !last.IsZero() && wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-s.p.ctx.Done():
+					timer.Stop()
+					return
+				case <-s.p.done:
+					timer.Stop()
+					return
+				case <-timer.C:
+				}
+			}
+			if !emit(s.p, out, v) {
+				return
+			}
+			last = time.Now()
+		}
+	}()
+	return &Stream[T]{p: s.p, ch: out}
+}
+
+// Encoder 将值编码为输出的字节
+type Encoder[T any] func(v T) ([]byte, error)
+
+// Encode 用 enc 编码每一项，得到可交给 Streamer 的字节流
+func Encode[T any](s *Stream[T], enc Encoder[T]) *Stream[[]byte] {
+	return Map(s, enc)
+}
+
+// JSONEncoder 以 JSON 编码
+func JSONEncoder[T any](v T) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// SSEData 将 enc 的输出封装为 SSE data 事件
+func SSEData[T any](enc Encoder[T]) Encoder[T] {
+	return func(v T) ([]byte, error) {
+		data, err := enc(v)
+		if err != nil {
+			return nil, err
+		}
+		return EncodeSSE(SSEMessage{Data: string(data)})
+	}
+}
+
+// EncodeSSE 将 msg 编码为 SSE 事件
+func EncodeSSE(msg SSEMessage) ([]byte, error) {
+	return formatSSEMessage(msg), nil
+}
+
+// WriteStream 在管道的 ctx 下用 streamer 写出编码后的流，返回 streamer 或管道的第一个错误，streamer 提前结束时停止管道
+func WriteStream(w http.ResponseWriter, streamer Streamer, s *Stream[[]byte]) error {
+	defer s.Stop()
+	if err := streamer.Stream(s.p.ctx, w, s.ch); err != nil {
+		return err
+	}
+	return s.Err()
+}
@@ -0,0 +1,160 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestStream_MapFilter(t *testing.T) {
+	s := FromSlice(context.Background(), 1, 2, 3, 4, 5)
+	even := s.Filter(func(n int) bool { return n%2 == 0 })
+	strs := Map(even, func(n int) (string, error) { return strconv.Itoa(n * 10), nil })
+
+	got, err := strs.Collect()
+	if err != nil || !reflect.DeepEqual(got, []string{"20", "40"}) {
+		t.Errorf("Unexpected result %v %v", got, err)
+	}
+}
+
+func TestStream_MapError(t *testing.T) {
+	src := make(chan int)
+	go func() {
+		defer close(src)
+		for i := 0; ; i++ {
+			select {
+			case src <- i:
+			case <-time.After(time.Second):
+				// 管道出错后源不再被读取
+				return
+			}
+		}
+	}()
+
+	boom := errors.New("boom")
+	s := Map(From(context.Background(), src), func(n int) (int, error) {
+		if n == 3 {
+			return 0, boom
+		}
+		return n, nil
+	})
+	got, err := s.Collect()
+	if !errors.Is(err, boom) || !reflect.DeepEqual(got, []int{0, 1, 2}) {
+		t.Errorf("Expected [0 1 2] and boom, got %v %v", got, err)
+	}
+}
+
+func TestStream_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	src := make(chan int)
+	s := From(ctx, src).Filter(func(int) bool { return true })
+	cancel()
+
+	select {
+	case _, ok := <-s.C():
+		if ok {
+			t.Fatal("Expected closed channel")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Stage did not stop after cancel")
+	}
+	if !errors.Is(s.Err(), context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", s.Err())
+	}
+}
+
+func TestBatch(t *testing.T) {
+	got, err := Batch(FromSlice(context.Background(), 1, 2, 3, 4, 5), 2, 0).Collect()
+	if err != nil || !reflect.DeepEqual(got, [][]int{{1, 2}, {3, 4}, {5}}) {
+		t.Errorf("Unexpected batches %v %v", got, err)
+	}
+
+	// 不足一批时按等待时间输出
+	src := make(chan int)
+	batches := Batch(From(context.Background(), src), 10, 20*time.Millisecond)
+	src <- 1
+	src <- 2
+	select {
+	case b := <-batches.C():
+		if !reflect.DeepEqual(b, []int{1, 2}) {
+			t.Errorf("Expected [1 2], got %v", b)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Partial batch not flushed after wait")
+	}
+	src <- 3
+	close(src)
+	if b := <-batches.C(); !reflect.DeepEqual(b, []int{3}) {
+		t.Errorf("Expected [3], got %v", b)
+	}
+}
+
+func TestStream_Tee(t *testing.T) {
+	outs := FromSlice(context.Background(), "a", "b", "c").Tee(2)
+	results := make(chan []string, 2)
+	for _, out := range outs {
+		go func() {
+			items, _ := out.Collect()
+			results <- items
+		}()
+	}
+	for range outs {
+		if got := <-results; !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+			t.Errorf("Unexpected tee output %v", got)
+		}
+	}
+}
+
+func TestStream_Throttle(t *testing.T) {
+	start := time.Now()
+	got, _ := FromSlice(context.Background(), 1, 2, 3).Throttle(20 * time.Millisecond).Collect()
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("Expected at least 40ms for 3 items, got %v", elapsed)
+	}
+	if !reflect.DeepEqual(got, []int{1, 2, 3}) {
+		t.Errorf("Unexpected items %v", got)
+	}
+}
+
+func TestEncode_SSE(t *testing.T) {
+	type chunk struct {
+		Content string `json:"content"`
+	}
+	w := httptest.NewRecorder()
+	s := Encode(FromSlice(context.Background(), chunk{"Hel"}, chunk{"lo"}), SSEData(JSONEncoder[chunk]))
+	if err := WriteStream(w, &SSEStream{}, s); err != nil {
+		t.Fatal(err)
+	}
+	expected := "data: {\"content\":\"Hel\"}\n\ndata: {\"content\":\"lo\"}\n\n"
+	if w.Body.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, w.Body.String())
+	}
+
+	retry := 500
+	data, _ := EncodeSSE(SSEMessage{ID: "7", Event: "delta", Data: "a\nb", Retry: &retry})
+	if string(data) != "id: 7\nevent: delta\ndata: a\ndata: b\nretry: 500\n\n" {
+		t.Errorf("Unexpected SSE encoding %q", data)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	boom := errors.New("boom")
+	var delivered []int
+	s := Generate(context.Background(), func(emit func(int) bool) error {
+		for i := 1; i <= 3; i++ {
+			if !emit(i) {
+				return nil
+			}
+			delivered = append(delivered, i)
+		}
+		return boom
+	})
+	got, err := s.Collect()
+	if !errors.Is(err, boom) || !reflect.DeepEqual(got, []int{1, 2, 3}) || !reflect.DeepEqual(delivered, got) {
+		t.Errorf("Expected [1 2 3] and boom, got %v %v", got, err)
+	}
+}
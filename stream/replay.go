@@ -0,0 +1,161 @@
+package stream
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// ReplayBuffer 以环形缓冲保存一个流最近的消息并依次编号（"1"、"2"…），
+// 重连的 EventSource 可按 Last-Event-ID 从断开处继续
+type ReplayBuffer struct {
+	mu     sync.Mutex
+	ring   []SSEMessage
+	nextID uint64 // 下一条消息的 ID
+	closed bool
+	notify chan struct{} // 缓冲变化时关闭并替换
+}
+
+// NewReplayBuffer 创建最多保存 size 条消息的缓冲
+func NewReplayBuffer(size int) *ReplayBuffer {
+	if size <= 0 {
+		size = 1
+	}
+	return &ReplayBuffer{
+		ring:   make([]SSEMessage, size),
+		nextID: 1,
+		notify: make(chan struct{}),
+	}
+}
+
+// Append 为 msg 分配 ID 并保存，唤醒等待的读取方，返回保存的消息。缓冲关闭后不做任何事
+func (b *ReplayBuffer) Append(msg SSEMessage) SSEMessage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return msg
+	}
+
+	msg.ID = strconv.FormatUint(b.nextID, 10)
+	b.ring[b.nextID%uint64(len(b.ring))] = msg
+	b.nextID++
+	b.broadcast()
+	return msg
+}
+
+// LastEventID 返回最后一条消息的 ID，还没有消息时为空
+func (b *ReplayBuffer) LastEventID() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.nextID == 1 {
+		return ""
+	}
+	return strconv.FormatUint(b.nextID-1, 10)
+}
+
+// Close 标记流已结束，读取方读完最后一条后结束
+func (b *ReplayBuffer) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.closed {
+		b.closed = true
+		b.broadcast()
+	}
+}
+
+func (b *ReplayBuffer) broadcast() {
+	close(b.notify)
+	b.notify = make(chan struct{})
+}
+
+// since 返回 ID 不小于 next 的缓冲消息（已淘汰的跳过）、下次变化时关闭的通道以及缓冲是否已关闭
+func (b *ReplayBuffer) since(next uint64) ([]SSEMessage, <-chan struct{}, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	size := uint64(len(b.ring))
+	if b.nextID > size && next < b.nextID-size {
+		next = b.nextID - size
+	}
+	var msgs []SSEMessage
+	for id := next; id < b.nextID; id++ {
+		msgs = append(msgs, b.ring[id%size])
+	}
+	return msgs, b.notify, b.closed
+}
+
+// Messages 先输出 lastID 之后的消息，再持续输出新消息，直到缓冲关闭或 ctx 结束。
+// lastID 为空或未知时从最早的缓冲消息开始
+func (b *ReplayBuffer) Messages(ctx context.Context, lastID string) <-chan SSEMessage {
+	b.mu.Lock()
+	next := b.resume(lastID)
+	b.mu.Unlock()
+
+	out := make(chan SSEMessage)
+	go func() {
+		defer close(out)
+		for {
+			msgs, changed, closed := b.since(next)
+			for _, msg := range msgs {
+				select {
+				case <-ctx.Done():
+					return
+				case out <- msg:
+				}
+			}
+			if len(msgs) > 0 {
+				next, _ = strconv.ParseUint(msgs[len(msgs)-1].ID, 10, 64)
+				next++
+				continue
+			}
+			if closed {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-changed:
+			}
+		}
+	}()
+	return out
+}
+
+// ServeHTTP 以 text/event-stream 输出缓冲，先补发 Last-Event-ID 之后的消息。
+// 已收到已结束流全部消息的客户端得到 204，使 EventSource 停止重连
+func (b *ReplayBuffer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	lastID := r.Header.Get("Last-Event-ID")
+	if b.done(lastID) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	events := Encode(From(r.Context(), b.Messages(r.Context(), lastID)), EncodeSSE)
+	_ = WriteStream(w, &SSEStream{}, events)
+}
+
+// done 报告缓冲已关闭且 lastID 是最后一条消息
+func (b *ReplayBuffer) done(lastID string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.closed && b.resume(lastID) == b.nextID
+}
+
+// Evicted 报告 lastID 之后是否已有消息被挤出缓冲，此时无法完整回放
+func (b *ReplayBuffer) Evicted(lastID string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	size := uint64(len(b.ring))
+	return b.nextID > size && b.resume(lastID) < b.nextID-size
+}
+
+// resume 返回 lastID 之后的第一个 ID。空的、无法解析的以及大于已分配 ID 的
+// lastID（来自过期或其他流）都视为未知，从头开始。调用方需持有锁
+func (b *ReplayBuffer) resume(lastID string) uint64 {
+	id, err := strconv.ParseUint(lastID, 10, 64)
+	if err != nil || id >= b.nextID {
+		return 1
+	}
+	return id + 1
+}
@@ -0,0 +1,147 @@
+package stream
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func dataOf(msgs []SSEMessage) string {
+	var parts []string
+	for _, msg := range msgs {
+		parts = append(parts, msg.ID+"="+msg.Data)
+	}
+	return strings.Join(parts, ",")
+}
+
+func TestReplayBuffer_Messages(t *testing.T) {
+	buf := NewReplayBuffer(10)
+	for _, data := range []string{"a", "b", "c"} {
+		buf.Append(SSEMessage{Data: data})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msgs := buf.Messages(ctx, "1")
+
+	var got []SSEMessage
+	got = append(got, <-msgs, <-msgs)
+
+	// 追上后继续接收实时消息，直到关闭
+	go func() {
+		buf.Append(SSEMessage{Event: "update", Data: "d"})
+		buf.Close()
+		buf.Append(SSEMessage{Data: "ignored"})
+	}()
+	got = append(got, collect(msgs)...)
+
+	if dataOf(got) != "2=b,3=c,4=d" || got[2].Event != "update" {
+		t.Errorf("Unexpected messages %+v", got)
+	}
+}
+
+func TestReplayBuffer_Eviction(t *testing.T) {
+	buf := NewReplayBuffer(2)
+	if id := buf.LastEventID(); id != "" {
+		t.Errorf("Expected no last event ID, got %q", id)
+	}
+	for _, data := range []string{"a", "b", "c", "d"} {
+		buf.Append(SSEMessage{Data: data})
+	}
+	buf.Close()
+	if id := buf.LastEventID(); id != "4" {
+		t.Errorf("Expected last event ID 4, got %q", id)
+	}
+
+	tests := []struct {
+		lastID   string
+		expected string
+		evicted  bool
+	}{
+		{"", "3=c,4=d", true},
+		{"1", "3=c,4=d", true},
+		{"2", "3=c,4=d", false},
+		{"3", "4=d", false},
+		{"bogus", "3=c,4=d", true},
+	}
+	for _, tt := range tests {
+		got := dataOf(collect(buf.Messages(context.Background(), tt.lastID)))
+		if got != tt.expected {
+			t.Errorf("Last-Event-ID %q: expected %q, got %q", tt.lastID, tt.expected, got)
+		}
+		if evicted := buf.Evicted(tt.lastID); evicted != tt.evicted {
+			t.Errorf("Last-Event-ID %q: expected evicted %v, got %v", tt.lastID, tt.evicted, evicted)
+		}
+	}
+}
+
+func TestReplayBuffer_ServeHTTP(t *testing.T) {
+	buf := NewReplayBuffer(10)
+	for _, data := range []string{"a", "b\nc", "d"} {
+		buf.Append(SSEMessage{Data: data})
+	}
+	buf.Close()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Last-Event-ID", "1")
+	buf.ServeHTTP(w, r)
+	if expected := "id: 2\ndata: b\ndata: c\n\nid: 3\ndata: d\n\n"; w.Body.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.Header.Set("Last-Event-ID", "3")
+	buf.ServeHTTP(w, r)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected 204 for a caught-up client, got %d", w.Code)
+	}
+
+	// 大于已分配 ID 的 Last-Event-ID 来自其他流，从头回放而不是一直等待
+	w = httptest.NewRecorder()
+	r.Header.Set("Last-Event-ID", "42")
+	buf.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Body.String(), "id: 1\n") {
+		t.Errorf("Expected full replay for a future ID, got %d %q", w.Code, w.Body.String())
+	}
+
+	live := NewReplayBuffer(10)
+	live.Append(SSEMessage{Data: "a"})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if msg := <-live.Messages(ctx, "7"); msg.ID != "1" {
+		t.Errorf("Expected replay from the start of an open buffer, got %+v", msg)
+	}
+}
+
+func TestReplayBuffer_Resume(t *testing.T) {
+	buf := NewReplayBuffer(10)
+	server := httptest.NewServer(buf)
+	defer server.Close()
+
+	for _, data := range []string{"a", "b", "c"} {
+		buf.Append(SSEMessage{Data: data})
+	}
+
+	// 模拟断线的客户端：已收到事件 1，重连后补齐剩余部分再继续实时接收
+	client := &SSEClient{URL: server.URL, LastEventID: "1", Retry: time.Millisecond}
+	events, err := client.Connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := []SSEMessage{<-events, <-events}
+	buf.Append(SSEMessage{Data: "d"})
+	buf.Close()
+	got = append(got, collect(events)...)
+
+	if client.Err() != nil {
+		t.Fatal(client.Err())
+	}
+	if dataOf(got) != "2=b,3=c,4=d" {
+		t.Errorf("Unexpected messages %+v", got)
+	}
+}
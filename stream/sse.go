@@ -1,17 +1,28 @@
 package stream
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 )
 
 type DataProcessor func(data []byte) ([]byte, error)
 
 type SSEStream struct {
 	Processor DataProcessor
+
+	Heartbeat      time.Duration // 空闲多久后发送心跳，避免代理断开连接，为 0 时不发送
+	HeartbeatEvent string        // 以该名称的空事件代替 ": ping" 注释发送心跳，便于客户端感知
+
+	// Flush 何时将写出的事件刷新给客户端，零值每个事件后刷新。Boundary 看到的是 Processor 之前的数据
+	Flush FlushPolicy
+
+	// heartbeats 测试时替代心跳定时器
+	heartbeats <-chan time.Time
 }
 
 func (s *SSEStream) Stream(ctx context.Context, w http.ResponseWriter, dataChan <-chan []byte) error {
@@ -26,15 +37,54 @@ func (s *SSEStream) Stream(ctx context.Context, w http.ResponseWriter, dataChan
 	w.Header().Set("Connection", "keep-alive")
 	w.Header().Set("X-Accel-Buffering", "no")
 
+	var heartbeat <-chan time.Time
+	resetHeartbeat := func() {}
+	if s.heartbeats != nil {
+		heartbeat = s.heartbeats
+	} else if s.Heartbeat > 0 {
+		timer := time.NewTimer(s.Heartbeat)
+		defer timer.Stop()
+		heartbeat = timer.C
+		resetHeartbeat = func() { timer.Reset(s.Heartbeat) }
+	}
+
+	pending := 0
+	delay := &flushTimer{interval: s.Flush.Interval}
+	defer delay.stop()
+	flush := func() {
+		delay.stop()
+		if pending > 0 {
+			flusher.Flush()
+			pending = 0
+		}
+	}
+
+	// 心跳只写在事件之间，不能插入到写了一半的事件中
+	boundary := true
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case data, open := <-dataChan:
+		case <-delay.C:
+			delay.C = nil
+			flush()
+		case <-heartbeat:
+			if boundary {
+				ping := s.ping()
+				if _, err := w.Write(ping); err != nil {
+					return err
+				}
+				pending += len(ping)
+				flush()
+			}
+			resetHeartbeat()
+		case item, open := <-dataChan:
 			if !open {
+				flush()
 				return nil
 			}
 
+			data := item
 			if s.Processor != nil {
 				var err error
 				data, err = s.Processor(data)
@@ -47,11 +97,35 @@ func (s *SSEStream) Stream(ctx context.Context, w http.ResponseWriter, dataChan
 			if err != nil {
 				return err
 			}
-			flusher.Flush()
+			pending += len(data)
+			if s.Flush.Due(pending, item) {
+				flush()
+			} else {
+				delay.start()
+			}
+
+			if len(data) > 0 {
+				boundary = endsEvent(data)
+			}
+			resetHeartbeat()
 		}
 	}
 }
 
+func (s *SSEStream) ping() []byte {
+	if s.HeartbeatEvent != "" {
+		return []byte("event: " + s.HeartbeatEvent + "\ndata: \n\n")
+	}
+	return []byte(": ping\n\n")
+}
+
+// endsEvent 报告 data 是否以空行结尾，即结束了一个事件
+func endsEvent(data []byte) bool {
+	return bytes.HasSuffix(data, []byte("\n\n")) ||
+		bytes.HasSuffix(data, []byte("\r\r")) ||
+		bytes.HasSuffix(data, []byte("\r\n\r\n"))
+}
+
 func SSEDataProcessor(data []byte) ([]byte, error) {
 	// Convert data to SSE format: data: <content>\n\n
 	sseData := fmt.Sprintf("data: %s\n\n", string(data))
@@ -71,6 +145,11 @@ func SSEMessageProcessor(data []byte) ([]byte, error) {
 		return nil, err
 	}
 
+	return formatSSEMessage(msg), nil
+}
+
+// formatSSEMessage 将 msg 编码为 SSE 格式
+func formatSSEMessage(msg SSEMessage) []byte {
 	var sseData strings.Builder
 
 	// Add ID field if provided
@@ -97,5 +176,5 @@ func SSEMessageProcessor(data []byte) ([]byte, error) {
 	// End message with double newline
 	sseData.WriteString("\n")
 
-	return []byte(sseData.String()), nil
+	return []byte(sseData.String())
 }
@@ -327,3 +327,98 @@ func BenchmarkSSEStream_Stream(b *testing.B) {
 		}
 	}
 }
+
+func TestSSEStream_Heartbeat(t *testing.T) {
+	tests := []struct {
+		name     string
+		sse      *SSEStream
+		steps    []string // "tick" 触发一次心跳，其余为写入的数据
+		expected string
+	}{
+		{
+			name:     "CommentWhileIdle",
+			sse:      &SSEStream{Processor: SSEDataProcessor},
+			steps:    []string{"tick", "tick", "a"},
+			expected: ": ping\n\n: ping\n\ndata: a\n\n",
+		},
+		{
+			name:     "NamedEvent",
+			sse:      &SSEStream{Processor: SSEDataProcessor, HeartbeatEvent: "ping"},
+			steps:    []string{"tick", "a"},
+			expected: "event: ping\ndata: \n\ndata: a\n\n",
+		},
+		{
+			name:     "NotInsidePartialEvent",
+			sse:      &SSEStream{},
+			steps:    []string{"data: par", "tick", "tial\n\n", "tick"},
+			expected: "data: partial\n\n: ping\n\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ticks := make(chan time.Time)
+			tt.sse.heartbeats = ticks
+			dataChan := make(chan []byte)
+			go func() {
+				// 通道无缓冲，每一步都在 Stream 处理完上一步之后才被接收
+				defer close(dataChan)
+				for _, step := range tt.steps {
+					if step == "tick" {
+						ticks <- time.Now()
+					} else {
+						dataChan <- []byte(step)
+					}
+				}
+			}()
+
+			w := httptest.NewRecorder()
+			if err := tt.sse.Stream(context.Background(), w, dataChan); err != nil {
+				t.Fatal(err)
+			}
+			if w.Body.String() != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, w.Body.String())
+			}
+		})
+	}
+}
+
+// signalWriter 每次 Flush 时通知测试
+type signalWriter struct {
+	httptest.ResponseRecorder
+	flushed chan string
+}
+
+func (w *signalWriter) Flush() {
+	w.flushed <- w.Body.String()
+}
+
+func TestSSEStream_HeartbeatTimer(t *testing.T) {
+	w := &signalWriter{ResponseRecorder: *httptest.NewRecorder(), flushed: make(chan string)}
+	dataChan := make(chan []byte)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- (&SSEStream{Heartbeat: time.Millisecond}).Stream(ctx, w, dataChan)
+	}()
+
+	// 只依赖顺序：空闲时最终会写出心跳
+	select {
+	case body := <-w.flushed:
+		if body != ": ping\n\n" {
+			t.Errorf("Expected a ping, got %q", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("No heartbeat while idle")
+	}
+	cancel()
+	go func() {
+		for range w.flushed {
+		}
+	}()
+	if err := <-errc; err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
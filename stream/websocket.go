@@ -0,0 +1,306 @@
+package stream
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// WebSocket 操作码（RFC 6455 5.2 节）
+const (
+	OpContinuation = 0x0
+	OpText         = 0x1
+	OpBinary       = 0x2
+	OpClose        = 0x8
+	OpPing         = 0x9
+	OpPong         = 0xA
+)
+
+// WebSocket 关闭码（RFC 6455 7.4.1 节）
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseUnsupportedData = 1003
+	CloseNoStatus        = 1005
+	CloseInvalidPayload  = 1007
+	CloseMessageTooBig   = 1009
+	CloseInternalError   = 1011
+)
+
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// DefaultReadLimit WebSocketConn.ReadLimit 为 0 时客户端消息的大小上限
+const DefaultReadLimit = 1 << 20
+
+// CloseError 对端关闭连接时由 ReadMessage 返回
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: closed with code %d %s", e.Code, e.Reason)
+}
+
+// WebSocketConn 服务端的 WebSocket 连接，写入可以并发，读取只能在单个 goroutine 中进行
+type WebSocketConn struct {
+	// ReadLimit 单条（合并分片后的）消息的大小上限，为 0 时使用 DefaultReadLimit
+	ReadLimit int
+
+	conn net.Conn
+	br   *bufio.Reader
+
+	mu         sync.Mutex
+	closeSent  bool
+	closeError error
+}
+
+// UpgradeWebSocket 完成握手并接管连接，失败时已写出 HTTP 错误响应
+func UpgradeWebSocket(w http.ResponseWriter, r *http.Request) (*WebSocketConn, error) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "websocket: method not allowed", http.StatusMethodNotAllowed)
+		return nil, errors.New("websocket: method not allowed")
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "websocket: upgrade required", http.StatusBadRequest)
+		return nil, errors.New("websocket: not a websocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "websocket: unsupported version", http.StatusUpgradeRequired)
+		return nil, errors.New("websocket: unsupported version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		http.Error(w, "websocket: invalid key", http.StatusBadRequest)
+		return nil, errors.New("websocket: invalid Sec-WebSocket-Key")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket: hijacking not supported", http.StatusInternalServerError)
+		return nil, errors.New("websocket: hijacking not supported")
+	}
+	conn, brw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+	if _, err = conn.Write([]byte(response)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &WebSocketConn{conn: conn, br: brw.Reader}, nil
+}
+
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, part := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ReadMessage 返回下一条合并分片后的文本或二进制消息，自动回复 ping、忽略 pong。
+// 收到关闭帧时回送并返回 *CloseError，协议错误以对应的关闭码关闭连接
+func (c *WebSocketConn) ReadMessage() (int, []byte, error) {
+	limit := c.ReadLimit
+	if limit <= 0 {
+		limit = DefaultReadLimit
+	}
+
+	var (
+		opcode  int
+		message []byte
+	)
+	for {
+		fin, op, payload, err := c.readFrame(limit - len(message))
+		if err != nil {
+			return 0, nil, c.fail(err)
+		}
+
+		switch op {
+		case OpPing:
+			if err = c.WriteMessage(OpPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case OpPong:
+			continue
+		case OpClose:
+			closeErr := parseClose(payload)
+			code := closeErr.Code
+			if code == CloseNoStatus {
+				code = CloseNormal
+			}
+			_ = c.Close(code, "")
+			return 0, nil, closeErr
+		case OpContinuation:
+			if opcode == 0 {
+				return 0, nil, c.fail(protocolError("unexpected continuation frame"))
+			}
+		case OpText, OpBinary:
+			if opcode != 0 {
+				return 0, nil, c.fail(protocolError("expected continuation frame"))
+			}
+			opcode = op
+		default:
+			return 0, nil, c.fail(protocolError(fmt.Sprintf("unknown opcode %d", op)))
+		}
+
+		message = append(message, payload...)
+		if fin {
+			if opcode == OpText && !utf8.Valid(message) {
+				return 0, nil, c.fail(&CloseError{Code: CloseInvalidPayload, Reason: "invalid UTF-8"})
+			}
+			return opcode, message, nil
+		}
+	}
+}
+
+// readFrame 读取一帧，客户端的帧必须带掩码
+func (c *WebSocketConn) readFrame(budget int) (bool, int, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.br, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin := header[0]&0x80 != 0
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, protocolError("reserved bits set")
+	}
+	op := int(header[0] & 0x0f)
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7f)
+
+	if !masked {
+		return false, 0, nil, protocolError("client frame not masked")
+	}
+	if op >= OpClose && (!fin || length > 125) {
+		return false, 0, nil, protocolError("invalid control frame")
+	}
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if op < OpClose && length > uint64(budget) {
+		return false, 0, nil, &CloseError{Code: CloseMessageTooBig, Reason: "message too big"}
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, op, payload, nil
+}
+
+func protocolError(reason string) error {
+	return &CloseError{Code: CloseProtocolError, Reason: reason}
+}
+
+func parseClose(payload []byte) *CloseError {
+	if len(payload) < 2 {
+		return &CloseError{Code: CloseNoStatus}
+	}
+	return &CloseError{Code: int(binary.BigEndian.Uint16(payload)), Reason: string(payload[2:])}
+}
+
+// fail 以协议错误对应的关闭码关闭连接
+func (c *WebSocketConn) fail(err error) error {
+	var closeErr *CloseError
+	if errors.As(err, &closeErr) {
+		_ = c.Close(closeErr.Code, closeErr.Reason)
+	} else {
+		c.conn.Close()
+	}
+	return err
+}
+
+// WriteMessage 以单个不分片的帧发送 data
+func (c *WebSocketConn) WriteMessage(opcode int, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closeSent {
+		return errors.New("websocket: connection closed")
+	}
+	return c.writeFrame(opcode, data)
+}
+
+func (c *WebSocketConn) writeFrame(opcode int, data []byte) error {
+	frame := make([]byte, 0, len(data)+10)
+	frame = append(frame, 0x80|byte(opcode))
+	switch {
+	case len(data) < 126:
+		frame = append(frame, byte(len(data)))
+	case len(data) <= 0xffff:
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(data)))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(data)))
+	}
+	frame = append(frame, data...)
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// Close 在尚未发送时发送关闭帧，并关闭连接
+func (c *WebSocketConn) Close(code int, reason string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closeSent {
+		return c.closeError
+	}
+	c.closeSent = true
+
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	payload = append(payload, reason...)
+	if len(payload) > 125 {
+		payload = payload[:125]
+	}
+	err := c.writeFrame(OpClose, payload)
+	if closeErr := c.conn.Close(); err == nil {
+		err = closeErr
+	}
+	c.closeError = err
+	return err
+}
@@ -0,0 +1,160 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+// 客户端控制消息类型，其他类型同样开始新的一轮，由 TurnFunc 解释
+const (
+	ControlCancel   = "cancel"   // 取消当前轮次
+	ControlContinue = "continue" // 开始一轮，接着上次的回复继续
+)
+
+// ControlMessage 客户端发送的 JSON 文本消息
+type ControlMessage struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// TurnFunc 为一条客户端消息开始生成数据。客户端发送 cancel 或连接结束时 ctx 被取消，
+// 返回的流结束即本轮结束，因错误停止时本轮失败
+type TurnFunc func(ctx context.Context, msg ControlMessage) (*Stream[[]byte], error)
+
+// statusMessage 轮次结束时服务端发送的 JSON 文本消息
+type statusMessage struct {
+	Type  string `json:"type"` // done, cancelled, error
+	Error string `json:"error,omitempty"`
+}
+
+// WebSocketStream 以与 SSEStream 相同的 Processor 在 WebSocket 上输出数据，
+// 客户端的每条消息开始一轮，cancel 取消当前轮次
+type WebSocketStream struct {
+	Processor DataProcessor
+	Sent      func(data []byte) // 可选，每条数据成功发送后在 Serve 的 goroutine 中调用，取消后丢弃的数据不会经过这里
+}
+
+// Serve 读取客户端消息直到连接关闭或 ctx 结束，同一时间只运行一轮。每轮的数据经 Processor 后以文本消息发送，
+// 结束时发送 {"type":"done"} 或 {"type":"cancelled"}，失败（包括流因错误停止）时只发送 {"type":"error"}
+func (s *WebSocketStream) Serve(ctx context.Context, conn *WebSocketConn, turn TurnFunc) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	messages := make(chan ControlMessage)
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			opcode, data, err := conn.ReadMessage()
+			if err != nil {
+				readErr <- err
+				return
+			}
+			if opcode != OpText {
+				continue
+			}
+
+			var msg ControlMessage
+			if err = json.Unmarshal(data, &msg); err != nil || msg.Type == "" {
+				_ = s.writeStatus(conn, statusMessage{Type: "error", Error: "invalid control message"})
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case messages <- msg:
+			}
+		}
+	}()
+
+	var (
+		current    *Stream[[]byte]
+		data       <-chan []byte
+		cancelTurn context.CancelFunc
+		cancelled  bool
+	)
+	defer func() {
+		if cancelTurn != nil {
+			cancelTurn()
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			_ = conn.Close(CloseGoingAway, "")
+			return ctx.Err()
+
+		case err := <-readErr:
+			var closeErr *CloseError
+			if errors.As(err, &closeErr) && (closeErr.Code == CloseNormal || closeErr.Code == CloseGoingAway || closeErr.Code == CloseNoStatus) {
+				return nil
+			}
+			return err
+
+		case msg := <-messages:
+			if msg.Type == ControlCancel {
+				if cancelTurn != nil {
+					cancelTurn()
+					cancelled = true
+				}
+				continue
+			}
+			if data != nil {
+				if err := s.writeStatus(conn, statusMessage{Type: "error", Error: "a turn is already running"}); err != nil {
+					return err
+				}
+				continue
+			}
+
+			turnCtx, turnCancel := context.WithCancel(ctx)
+			turnData, err := turn(turnCtx, msg)
+			if err != nil {
+				turnCancel()
+				if err = s.writeStatus(conn, statusMessage{Type: "error", Error: err.Error()}); err != nil {
+					return err
+				}
+				continue
+			}
+			current, data, cancelTurn, cancelled = turnData, turnData.C(), turnCancel, false
+
+		case chunk, open := <-data:
+			if !open {
+				status := statusMessage{Type: "done"}
+				if cancelled {
+					status.Type = "cancelled"
+				} else if err := current.Err(); err != nil {
+					status = statusMessage{Type: "error", Error: err.Error()}
+				}
+				cancelTurn()
+				current, data, cancelTurn = nil, nil, nil
+				if err := s.writeStatus(conn, status); err != nil {
+					return err
+				}
+				continue
+			}
+			// 取消后继续读取直到生产者停止，但不再发送
+			if cancelled {
+				continue
+			}
+
+			if s.Processor != nil {
+				var err error
+				if chunk, err = s.Processor(chunk); err != nil {
+					return err
+				}
+			}
+			if err := conn.WriteMessage(OpText, chunk); err != nil {
+				return err
+			}
+			if s.Sent != nil {
+				s.Sent(chunk)
+			}
+		}
+	}
+}
+
+func (s *WebSocketStream) writeStatus(conn *WebSocketConn, status statusMessage) error {
+	data, _ := json.Marshal(status)
+	return conn.WriteMessage(OpText, data)
+}
@@ -0,0 +1,313 @@
+package stream
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wsClient 测试用的最小 WebSocket 客户端
+type wsClient struct {
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+func dialWebSocket(t *testing.T, url string) *wsClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+	req := "GET / HTTP/1.1\r\nHost: test\r\nUpgrade: websocket\r\nConnection: keep-alive, Upgrade\r\n" +
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: " + key + "\r\n\r\n"
+	if _, err = conn.Write([]byte(req)); err != nil {
+		t.Fatal(err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("Unexpected handshake response %d %v", resp.StatusCode, resp.Header)
+	}
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	return &wsClient{conn: conn, br: br}
+}
+
+func (c *wsClient) writeFrame(fin bool, opcode int, payload []byte, masked bool) {
+	b0 := byte(opcode)
+	if fin {
+		b0 |= 0x80
+	}
+	frame := []byte{b0}
+	b1 := byte(0)
+	if masked {
+		b1 = 0x80
+	}
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, b1|byte(len(payload)))
+	default:
+		frame = append(frame, b1|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	}
+	data := append([]byte(nil), payload...)
+	if masked {
+		mask := []byte{1, 2, 3, 4}
+		frame = append(frame, mask...)
+		for i := range data {
+			data[i] ^= mask[i%4]
+		}
+	}
+	_, _ = c.conn.Write(append(frame, data...))
+}
+
+func (c *wsClient) send(v any) {
+	data, _ := json.Marshal(v)
+	c.writeFrame(true, OpText, data, true)
+}
+
+func (c *wsClient) readFrame(t *testing.T) (int, []byte) {
+	t.Helper()
+	var header [2]byte
+	if _, err := io.ReadFull(c.br, header[:]); err != nil {
+		t.Fatal(err)
+	}
+	length := int(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		_, _ = io.ReadFull(c.br, ext[:])
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		_, _ = io.ReadFull(c.br, ext[:])
+		length = int(binary.BigEndian.Uint64(ext[:]))
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		t.Fatal(err)
+	}
+	return int(header[0] & 0x0f), payload
+}
+
+func closeCode(payload []byte) int {
+	return int(binary.BigEndian.Uint16(payload))
+}
+
+func TestUpgradeWebSocket_Rejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		status  int
+	}{
+		{"NotUpgrade", map[string]string{"Sec-WebSocket-Version": "13"}, http.StatusBadRequest},
+		{"Version", map[string]string{"Connection": "Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Version": "8"}, http.StatusUpgradeRequired},
+		{"Key", map[string]string{"Connection": "Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Version": "13", "Sec-WebSocket-Key": "short"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			if _, err := UpgradeWebSocket(w, r); err == nil || w.Code != tt.status {
+				t.Errorf("Expected status %d with error, got %d %v", tt.status, w.Code, err)
+			}
+		})
+	}
+}
+
+func TestWebSocketConn_Echo(t *testing.T) {
+	serverErr := make(chan error, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := UpgradeWebSocket(w, r)
+		if err != nil {
+			serverErr <- err
+			return
+		}
+		for {
+			opcode, data, err := conn.ReadMessage()
+			if err != nil {
+				serverErr <- err
+				return
+			}
+			_ = conn.WriteMessage(opcode, data)
+		}
+	}))
+	defer server.Close()
+
+	client := dialWebSocket(t, server.URL)
+
+	// 分片的文本消息，中间插入 ping
+	client.writeFrame(false, OpText, []byte("hel"), true)
+	client.writeFrame(true, OpPing, []byte("p"), true)
+	client.writeFrame(true, OpContinuation, []byte("lo"), true)
+	if op, payload := client.readFrame(t); op != OpPong || string(payload) != "p" {
+		t.Fatalf("Expected pong, got %d %q", op, payload)
+	}
+	if op, payload := client.readFrame(t); op != OpText || string(payload) != "hello" {
+		t.Fatalf("Expected echoed hello, got %d %q", op, payload)
+	}
+
+	long := []byte(strings.Repeat("x", 300))
+	client.writeFrame(true, OpBinary, long, true)
+	if op, payload := client.readFrame(t); op != OpBinary || string(payload) != string(long) {
+		t.Fatalf("Expected echoed binary of %d bytes, got %d %d", len(long), op, len(payload))
+	}
+
+	client.writeFrame(true, OpClose, binary.BigEndian.AppendUint16(nil, CloseNormal), true)
+	if op, payload := client.readFrame(t); op != OpClose || closeCode(payload) != CloseNormal {
+		t.Fatalf("Expected close echo, got %d %v", op, payload)
+	}
+	var closeErr *CloseError
+	if err := <-serverErr; !errors.As(err, &closeErr) || closeErr.Code != CloseNormal {
+		t.Errorf("Expected CloseError 1000, got %v", err)
+	}
+}
+
+func TestWebSocketConn_ProtocolErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(c *wsClient)
+		code  int
+	}{
+		{"Unmasked", func(c *wsClient) { c.writeFrame(true, OpText, []byte("hi"), false) }, CloseProtocolError},
+		{"UnexpectedContinuation", func(c *wsClient) { c.writeFrame(true, OpContinuation, []byte("hi"), true) }, CloseProtocolError},
+		{"InvalidUTF8", func(c *wsClient) { c.writeFrame(true, OpText, []byte{0xff, 0xfe}, true) }, CloseInvalidPayload},
+		{"TooBig", func(c *wsClient) { c.writeFrame(true, OpBinary, make([]byte, 200), true) }, CloseMessageTooBig},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				conn, err := UpgradeWebSocket(w, r)
+				if err != nil {
+					return
+				}
+				conn.ReadLimit = 100
+				_, _, _ = conn.ReadMessage()
+			}))
+			defer server.Close()
+
+			client := dialWebSocket(t, server.URL)
+			tt.write(client)
+			if op, payload := client.readFrame(t); op != OpClose || closeCode(payload) != tt.code {
+				t.Errorf("Expected close %d, got %d %v", tt.code, op, payload)
+			}
+		})
+	}
+}
+
+func TestWebSocketStream_Serve(t *testing.T) {
+	turns := make(chan ControlMessage, 8)
+	turnCancelled := make(chan struct{})
+	turn := func(ctx context.Context, msg ControlMessage) (*Stream[[]byte], error) {
+		turns <- msg
+		if msg.Type == "fail" {
+			return nil, errors.New("no provider")
+		}
+		return Generate(ctx, func(emit func([]byte) bool) error {
+			for _, word := range []string{"Hel", "lo"} {
+				if !emit([]byte(word)) {
+					return nil
+				}
+				if msg.Type == "broken" {
+					return errors.New("upstream failed")
+				}
+			}
+			if msg.Type == "slow" {
+				<-ctx.Done()
+				close(turnCancelled)
+			}
+			return nil
+		}), nil
+	}
+
+	var sent []string
+	serveErr := make(chan error, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := UpgradeWebSocket(w, r)
+		if err != nil {
+			serveErr <- err
+			return
+		}
+		ws := &WebSocketStream{Processor: func(data []byte) ([]byte, error) {
+			return append([]byte("> "), data...), nil
+		}, Sent: func(data []byte) {
+			sent = append(sent, string(data))
+		}}
+		serveErr <- ws.Serve(context.Background(), conn, turn)
+	}))
+	defer server.Close()
+
+	client := dialWebSocket(t, server.URL)
+	expect := func(want string) {
+		t.Helper()
+		if _, payload := client.readFrame(t); string(payload) != want {
+			t.Fatalf("Expected %q, got %q", want, payload)
+		}
+	}
+
+	client.send(ControlMessage{Type: "message", Data: json.RawMessage(`"hi"`)})
+	expect("> Hel")
+	expect("> lo")
+	expect(`{"type":"done"}`)
+
+	// 生成过程中取消，只取消本次生成，连接保持
+	client.send(ControlMessage{Type: "slow"})
+	expect("> Hel")
+	expect("> lo")
+	client.send(ControlMessage{Type: ControlCancel})
+	<-turnCancelled
+	expect(`{"type":"cancelled"}`)
+
+	client.send(ControlMessage{Type: ControlContinue})
+	expect("> Hel")
+	expect("> lo")
+	expect(`{"type":"done"}`)
+
+	// 生成中途出错时只发送错误，不再发送 done
+	client.send(ControlMessage{Type: "broken"})
+	expect("> Hel")
+	expect(`{"type":"error","error":"upstream failed"}`)
+
+	client.send(ControlMessage{Type: "fail"})
+	expect(`{"type":"error","error":"no provider"}`)
+	client.writeFrame(true, OpText, []byte("not json"), true)
+	expect(`{"type":"error","error":"invalid control message"}`)
+
+	var types []string
+	for len(turns) > 0 {
+		types = append(types, (<-turns).Type)
+	}
+	if strings.Join(types, ",") != "message,slow,continue,broken,fail" {
+		t.Errorf("Unexpected turns %v", types)
+	}
+
+	client.writeFrame(true, OpClose, binary.BigEndian.AppendUint16(nil, CloseNormal), true)
+	if op, _ := client.readFrame(t); op != OpClose {
+		t.Errorf("Expected close echo, got opcode %d", op)
+	}
+	if err := <-serveErr; err != nil {
+		t.Errorf("Expected clean shutdown, got %v", err)
+	}
+	// Sent 只收到实际发送的数据，不包括状态消息
+	if got := strings.Join(sent, ","); got != "> Hel,> lo,> Hel,> lo,> Hel,> lo,> Hel" {
+		t.Errorf("Unexpected sent data %q", got)
+	}
+}
@@ -0,0 +1,102 @@
+package tokenizer
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	CL100kBase = "cl100k_base"
+	O200kBase  = "o200k_base"
+)
+
+// 各编码的特殊 token
+var specialTokens = map[string]map[string]int{
+	CL100kBase: {
+		"<|endoftext|>":   100257,
+		"<|fim_prefix|>":  100258,
+		"<|fim_middle|>":  100259,
+		"<|fim_suffix|>":  100260,
+		"<|endofprompt|>": 100276,
+	},
+	O200kBase: {
+		"<|endoftext|>":   199999,
+		"<|endofprompt|>": 200018,
+	},
+}
+
+// 模型名前缀 -> 编码，按顺序匹配
+var modelPrefixes = []struct {
+	prefix   string
+	encoding string
+}{
+	{"gpt-4o", O200kBase},
+	{"gpt-4.1", O200kBase},
+	{"gpt-5", O200kBase},
+	{"o1", O200kBase},
+	{"o3", O200kBase},
+	{"o4", O200kBase},
+	{"gpt-4", CL100kBase},
+	{"gpt-3.5", CL100kBase},
+	{"text-embedding-3", CL100kBase},
+	{"text-embedding-ada", CL100kBase},
+}
+
+// EncodingForModel 返回模型对应的编码名，未知模型默认 cl100k_base
+func EncodingForModel(model string) string {
+	for _, m := range modelPrefixes {
+		if strings.HasPrefix(model, m.prefix) {
+			return m.encoding
+		}
+	}
+	return CL100kBase
+}
+
+// LoadEncoding 从 rank 文件加载指定编码
+func LoadEncoding(name, path string) (*Encoding, error) {
+	special, ok := specialTokens[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown encoding %s", name)
+	}
+	ranks, err := LoadRanks(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewEncoding(name, ranks, special)
+}
+
+// Registry 从目录按需加载并缓存编码，文件名为 <name>.tiktoken
+type Registry struct {
+	dir       string
+	encodings map[string]*Encoding
+	mu        sync.Mutex
+}
+
+func NewRegistry(dir string) *Registry {
+	return &Registry{
+		dir:       dir,
+		encodings: make(map[string]*Encoding),
+	}
+}
+
+func (r *Registry) GetEncoding(name string) (*Encoding, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if enc, ok := r.encodings[name]; ok {
+		return enc, nil
+	}
+
+	enc, err := LoadEncoding(name, filepath.Join(r.dir, name+".tiktoken"))
+	if err != nil {
+		return nil, err
+	}
+	r.encodings[name] = enc
+	return enc, nil
+}
+
+func (r *Registry) ForModel(model string) (*Encoding, error) {
+	return r.GetEncoding(EncodingForModel(model))
+}
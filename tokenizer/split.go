@@ -0,0 +1,236 @@
+package tokenizer
+
+import (
+	"unicode"
+)
+
+// 预分词器，手工实现 tiktoken 的正则（Go regexp 不支持 (?!\S) 前瞻）
+var splitters = map[string]func(string) []string{
+	CL100kBase: splitCL100k,
+	O200kBase:  splitO200k,
+}
+
+// matcher 尝试在位置 i 匹配，返回匹配结束位置，未匹配返回 -1
+type matcher func(rs []rune, i int) int
+
+func splitWith(text string, alternatives []matcher) []string {
+	rs := []rune(text)
+	var pieces []string
+	for i := 0; i < len(rs); {
+		end := -1
+		for _, m := range alternatives {
+			if end = m(rs, i); end > i {
+				break
+			}
+		}
+		if end <= i {
+			// 兜底：单个字符成段，保证前进
+			end = i + 1
+		}
+		pieces = append(pieces, string(rs[i:end]))
+		i = end
+	}
+	return pieces
+}
+
+// cl100k_base:
+// (?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+
+var cl100kMatchers = []matcher{
+	matchContraction,
+	matchPrefixedLetters,
+	matchNumbers,
+	punctuationMatcher(isNewline),
+	matchNewlines,
+	matchTrailingSpaces,
+	matchSpaces,
+}
+
+func splitCL100k(text string) []string {
+	return splitWith(text, cl100kMatchers)
+}
+
+// o200k_base:
+// [^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?
+// |[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?
+// |\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n/]*|\s*[\r\n]+|\s+(?!\S)|\s+
+var o200kMatchers = []matcher{
+	matchCasedWord(false),
+	matchCasedWord(true),
+	matchNumbers,
+	punctuationMatcher(func(r rune) bool { return isNewline(r) || r == '/' }),
+	matchNewlines,
+	matchTrailingSpaces,
+	matchSpaces,
+}
+
+func splitO200k(text string) []string {
+	return splitWith(text, o200kMatchers)
+}
+
+func isNewline(r rune) bool {
+	return r == '\r' || r == '\n'
+}
+
+func isLetterOrNumber(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
+
+// isWordPrefix [^\r\n\p{L}\p{N}]
+func isWordPrefix(r rune) bool {
+	return !isNewline(r) && !isLetterOrNumber(r)
+}
+
+// isUpperish [\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]
+func isUpperish(r rune) bool {
+	return unicode.In(r, unicode.Lu, unicode.Lt, unicode.Lm, unicode.Lo, unicode.M)
+}
+
+// isLowerish [\p{Ll}\p{Lm}\p{Lo}\p{M}]
+func isLowerish(r rune) bool {
+	return unicode.In(r, unicode.Ll, unicode.Lm, unicode.Lo, unicode.M)
+}
+
+var contractions = []string{"s", "t", "re", "ve", "m", "ll", "d"}
+
+// matchContraction (?i:'s|'t|'re|'ve|'m|'ll|'d)
+func matchContraction(rs []rune, i int) int {
+	if i >= len(rs) || rs[i] != '\'' {
+		return -1
+	}
+	for _, c := range contractions {
+		end := i + 1 + len(c)
+		if end > len(rs) {
+			continue
+		}
+		ok := true
+		for k, ch := range c {
+			if unicode.ToLower(rs[i+1+k]) != ch {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return end
+		}
+	}
+	return -1
+}
+
+func scan(rs []rune, i int, pred func(rune) bool) int {
+	for i < len(rs) && pred(rs[i]) {
+		i++
+	}
+	return i
+}
+
+// matchPrefixedLetters [^\r\n\p{L}\p{N}]?\p{L}+
+func matchPrefixedLetters(rs []rune, i int) int {
+	start := i
+	if isWordPrefix(rs[i]) {
+		start++
+	}
+	if end := scan(rs, start, unicode.IsLetter); end > start {
+		return end
+	}
+	return -1
+}
+
+// matchNumbers \p{N}{1,3}
+func matchNumbers(rs []rune, i int) int {
+	end := i
+	for end < len(rs) && end-i < 3 && unicode.IsNumber(rs[end]) {
+		end++
+	}
+	if end > i {
+		return end
+	}
+	return -1
+}
+
+// punctuationMatcher ` ?[^\s\p{L}\p{N}]+` 后接 trailing 字符集
+func punctuationMatcher(trailing func(rune) bool) matcher {
+	isPunct := func(r rune) bool {
+		return !unicode.IsSpace(r) && !isLetterOrNumber(r)
+	}
+	return func(rs []rune, i int) int {
+		start := i
+		if rs[i] == ' ' {
+			start++
+		}
+		end := scan(rs, start, isPunct)
+		if end == start {
+			return -1
+		}
+		return scan(rs, end, trailing)
+	}
+}
+
+// matchNewlines \s*[\r\n]+
+func matchNewlines(rs []rune, i int) int {
+	end := scan(rs, i, unicode.IsSpace)
+	for k := end - 1; k >= i; k-- {
+		if isNewline(rs[k]) {
+			return k + 1
+		}
+	}
+	return -1
+}
+
+// matchTrailingSpaces \s+(?!\S)
+func matchTrailingSpaces(rs []rune, i int) int {
+	end := scan(rs, i, unicode.IsSpace)
+	if end == len(rs) && end > i {
+		return end
+	}
+	// 回退一个空白字符，使其后仍为空白
+	if end-1 > i {
+		return end - 1
+	}
+	return -1
+}
+
+// matchSpaces \s+
+func matchSpaces(rs []rune, i int) int {
+	if end := scan(rs, i, unicode.IsSpace); end > i {
+		return end
+	}
+	return -1
+}
+
+// matchCasedWord o200k 的两种大小写单词规则，upperFirst 对应第二条
+func matchCasedWord(upperFirst bool) matcher {
+	word := func(rs []rune, start int) int {
+		u := scan(rs, start, isUpperish)
+		if upperFirst {
+			if u == start {
+				return -1
+			}
+			return scan(rs, u, isLowerish)
+		}
+		if l := scan(rs, u, isLowerish); l > u {
+			return l
+		}
+		// 回退：U* 末尾的字符若同时属于小写类，可让给 L+
+		if u > start && isLowerish(rs[u-1]) {
+			return u
+		}
+		return -1
+	}
+
+	return func(rs []rune, i int) int {
+		end := -1
+		if isWordPrefix(rs[i]) {
+			end = word(rs, i+1)
+		}
+		if end < 0 {
+			end = word(rs, i)
+		}
+		if end < 0 {
+			return -1
+		}
+		if c := matchContraction(rs, end); c > 0 {
+			return c
+		}
+		return end
+	}
+}
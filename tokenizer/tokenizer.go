@@ -0,0 +1,160 @@
+package tokenizer
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Encoding BPE 编码器
+type Encoding struct {
+	Name string
+
+	ranks          map[string]int // token 字节序列 -> rank
+	decoder        map[int]string // rank -> token 字节序列
+	special        map[string]int // 特殊 token -> id
+	specialDecoder map[int]string // id -> 特殊 token
+	split          func(string) []string
+}
+
+// NewEncoding 使用 rank 表创建编码器，name 决定预分词规则（cl100k_base / o200k_base）
+func NewEncoding(name string, ranks map[string]int, special map[string]int) (*Encoding, error) {
+	split, ok := splitters[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown encoding %s", name)
+	}
+	if len(ranks) == 0 {
+		return nil, fmt.Errorf("encoding %s has no ranks", name)
+	}
+
+	enc := &Encoding{
+		Name:           name,
+		ranks:          ranks,
+		decoder:        make(map[int]string, len(ranks)),
+		special:        make(map[string]int, len(special)),
+		specialDecoder: make(map[int]string, len(special)),
+		split:          split,
+	}
+	for token, rank := range ranks {
+		enc.decoder[rank] = token
+	}
+	for token, id := range special {
+		enc.special[token] = id
+		enc.specialDecoder[id] = token
+	}
+	return enc, nil
+}
+
+// Encode 编码文本，特殊 token 按普通文本处理
+func (e *Encoding) Encode(text string) []int {
+	var tokens []int
+	for _, piece := range e.split(text) {
+		tokens = e.encodePiece(tokens, piece)
+	}
+	return tokens
+}
+
+// EncodeWithSpecial 编码文本，识别其中的特殊 token
+func (e *Encoding) EncodeWithSpecial(text string) []int {
+	var tokens []int
+	for len(text) > 0 {
+		idx, token := e.nextSpecial(text)
+		if idx < 0 {
+			tokens = append(tokens, e.Encode(text)...)
+			break
+		}
+		tokens = append(tokens, e.Encode(text[:idx])...)
+		tokens = append(tokens, e.special[token])
+		text = text[idx+len(token):]
+	}
+	return tokens
+}
+
+// Decode 将 token 序列还原为文本
+func (e *Encoding) Decode(tokens []int) string {
+	var sb strings.Builder
+	for _, t := range tokens {
+		if s, ok := e.decoder[t]; ok {
+			sb.WriteString(s)
+		} else if s, ok := e.specialDecoder[t]; ok {
+			sb.WriteString(s)
+		}
+	}
+	return sb.String()
+}
+
+// Count 统计文本的 token 数
+func (e *Encoding) Count(text string) int {
+	n := 0
+	for _, piece := range e.split(text) {
+		if _, ok := e.ranks[piece]; ok {
+			n++
+			continue
+		}
+		n += len(e.bytePairMerge(piece))
+	}
+	return n
+}
+
+// nextSpecial 查找最靠前的特殊 token，相同位置取最长
+func (e *Encoding) nextSpecial(text string) (int, string) {
+	idx, found := -1, ""
+	for token := range e.special {
+		i := strings.Index(text, token)
+		if i < 0 {
+			continue
+		}
+		if idx < 0 || i < idx || (i == idx && len(token) > len(found)) {
+			idx, found = i, token
+		}
+	}
+	return idx, found
+}
+
+func (e *Encoding) encodePiece(tokens []int, piece string) []int {
+	if rank, ok := e.ranks[piece]; ok {
+		return append(tokens, rank)
+	}
+	for _, part := range e.bytePairMerge(piece) {
+		tokens = append(tokens, e.ranks[part])
+	}
+	return tokens
+}
+
+// bytePairMerge 按 rank 从小到大合并相邻字节对
+func (e *Encoding) bytePairMerge(piece string) []string {
+	// parts[i] 为第 i 段的起始偏移，最后一项为 len(piece)
+	parts := make([]int, len(piece)+1)
+	for i := range parts {
+		parts[i] = i
+	}
+
+	rankOf := func(i int) int {
+		if i+2 >= len(parts) {
+			return math.MaxInt
+		}
+		if r, ok := e.ranks[piece[parts[i]:parts[i+2]]]; ok {
+			return r
+		}
+		return math.MaxInt
+	}
+
+	for len(parts) > 2 {
+		minRank, minIdx := math.MaxInt, -1
+		for i := 0; i < len(parts)-2; i++ {
+			if r := rankOf(i); r < minRank {
+				minRank, minIdx = r, i
+			}
+		}
+		if minIdx < 0 {
+			break
+		}
+		parts = append(parts[:minIdx+1], parts[minIdx+2:]...)
+	}
+
+	result := make([]string, 0, len(parts)-1)
+	for i := 0; i < len(parts)-1; i++ {
+		result = append(result, piece[parts[i]:parts[i+1]])
+	}
+	return result
+}
@@ -0,0 +1,126 @@
+package tokenizer
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testRanks 构造一个小型 rank 表：256 个单字节 + 若干合并
+func testRanks() map[string]int {
+	ranks := make(map[string]int)
+	for i := 0; i < 256; i++ {
+		ranks[string([]byte{byte(i)})] = i
+	}
+	for i, merge := range []string{"he", "ll", "hell", "hello", " w", "or", " wor", "ld", " world"} {
+		ranks[merge] = 256 + i
+	}
+	return ranks
+}
+
+func TestSplitCL100k(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected []string
+	}{
+		{"Hello world", []string{"Hello", " world"}},
+		{"I'm fine", []string{"I", "'m", " fine"}},
+		{"12345", []string{"123", "45"}},
+		{"a  b", []string{"a", " ", " b"}},
+		{"hi!!\n\nbye", []string{"hi", "!!\n\n", "bye"}},
+		{"end  ", []string{"end", "  "}},
+		{"line\n  next", []string{"line", "\n", " ", " next"}},
+		{"中文测试", []string{"中文测试"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := splitCL100k(tt.text); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestSplitO200k(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected []string
+	}{
+		{"Hello world", []string{"Hello", " world"}},
+		{"HTTPServer", []string{"HTTPServer"}},
+		{"don't", []string{"don't"}},
+		{"a/b//\n", []string{"a", "/b", "//\n"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := splitO200k(tt.text); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestEncoding_EncodeDecode(t *testing.T) {
+	enc, err := NewEncoding(CL100kBase, testRanks(), specialTokens[CL100kBase])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokens := enc.Encode("hello world")
+	if expected := []int{259, 264}; !reflect.DeepEqual(tokens, expected) {
+		t.Errorf("Expected %v, got %v", expected, tokens)
+	}
+	if got := enc.Decode(tokens); got != "hello world" {
+		t.Errorf("Expected %q, got %q", "hello world", got)
+	}
+	if n := enc.Count("hello world!"); n != 3 {
+		t.Errorf("Expected 3 tokens, got %d", n)
+	}
+
+	text := "hello<|endoftext|>"
+	if tokens := enc.EncodeWithSpecial(text); !reflect.DeepEqual(tokens, []int{259, 100257}) {
+		t.Errorf("Expected special token, got %v", tokens)
+	}
+	if tokens := enc.Encode(text); len(tokens) <= 2 {
+		t.Errorf("Expected special token to be encoded as text, got %v", tokens)
+	}
+	if got := enc.Decode(enc.EncodeWithSpecial(text)); got != text {
+		t.Errorf("Expected %q, got %q", text, got)
+	}
+}
+
+func TestLoadEncoding(t *testing.T) {
+	var sb strings.Builder
+	for token, rank := range testRanks() {
+		fmt.Fprintf(&sb, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(token)), rank)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "o200k_base.tiktoken"), []byte(sb.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	registry := NewRegistry(dir)
+	enc, err := registry.ForModel("gpt-4o-mini")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enc.Name != O200kBase {
+		t.Errorf("Expected %s, got %s", O200kBase, enc.Name)
+	}
+	if got := enc.Decode(enc.Encode("hello world")); got != "hello world" {
+		t.Errorf("Expected round trip, got %q", got)
+	}
+
+	if _, err = registry.ForModel("gpt-4"); err == nil {
+		t.Error("Expected error for missing rank file")
+	}
+	if _, err = ParseRanks(strings.NewReader("aGVsbG8=\n")); err == nil {
+		t.Error("Expected error for malformed rank line")
+	}
+}
@@ -0,0 +1,46 @@
+package vectorstore
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+// FlatIndex 暴力检索索引，结果精确，适合小规模数据
+type FlatIndex struct {
+	vectors [][]float32
+}
+
+func NewFlatIndex() *FlatIndex {
+	return &FlatIndex{}
+}
+
+func (f *FlatIndex) Add(id int, vector []float32) {
+	for len(f.vectors) <= id {
+		f.vectors = append(f.vectors, nil)
+	}
+	f.vectors[id] = vector
+}
+
+func (f *FlatIndex) Search(query []float32, k int) []Hit {
+	hits := make([]Hit, 0, len(f.vectors))
+	for id, v := range f.vectors {
+		if v != nil {
+			hits = append(hits, Hit{ID: id, Score: dot(query, v)})
+		}
+	}
+	sortHits(hits)
+	if len(hits) > k {
+		hits = hits[:k]
+	}
+	return hits
+}
+
+func (f *FlatIndex) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(f.vectors)
+	return buf.Bytes(), err
+}
+
+func (f *FlatIndex) GobDecode(data []byte) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(&f.vectors)
+}
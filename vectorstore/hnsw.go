@@ -0,0 +1,239 @@
+package vectorstore
+
+import (
+	"bytes"
+	"container/heap"
+	"encoding/gob"
+	"math"
+	"math/rand/v2"
+)
+
+// HNSWOptions HNSW 索引参数
+type HNSWOptions struct {
+	M              int // 每层的最大邻居数，第 0 层为 2M
+	EfConstruction int // 构建时的候选集大小
+	EfSearch       int // 检索时的候选集大小
+	Seed           uint64
+}
+
+// HNSWIndex 分层可导航小世界图索引，近似检索，适合较大规模数据
+type HNSWIndex struct {
+	options   HNSWOptions
+	levelMult float64
+	rng       *rand.Rand
+
+	vectors   [][]float32
+	neighbors [][][]int // 节点 -> 层 -> 邻居
+	entry     int
+	maxLevel  int
+}
+
+func NewHNSWIndex(options HNSWOptions) *HNSWIndex {
+	if options.M <= 0 {
+		options.M = 16
+	}
+	if options.EfConstruction <= 0 {
+		options.EfConstruction = 200
+	}
+	if options.EfSearch <= 0 {
+		options.EfSearch = 64
+	}
+	return &HNSWIndex{
+		options:   options,
+		levelMult: 1 / math.Log(float64(options.M)),
+		rng:       rand.New(rand.NewPCG(options.Seed, options.Seed)),
+		entry:     -1,
+	}
+}
+
+func (h *HNSWIndex) Add(id int, vector []float32) {
+	for len(h.vectors) <= id {
+		h.vectors = append(h.vectors, nil)
+		h.neighbors = append(h.neighbors, nil)
+	}
+	h.vectors[id] = vector
+
+	level := int(math.Floor(-math.Log(1-h.rng.Float64()) * h.levelMult))
+	h.neighbors[id] = make([][]int, level+1)
+
+	if h.entry < 0 {
+		h.entry, h.maxLevel = id, level
+		return
+	}
+
+	ep := h.entry
+	for l := h.maxLevel; l > level; l-- {
+		ep = h.greedy(vector, ep, l)
+	}
+	entries := []int{ep}
+	for l := min(level, h.maxLevel); l >= 0; l-- {
+		candidates := h.searchLayer(vector, entries, h.options.EfConstruction, l)
+		selected := candidates
+		if len(selected) > h.options.M {
+			selected = selected[:h.options.M]
+		}
+		for _, c := range selected {
+			h.neighbors[id][l] = append(h.neighbors[id][l], c.ID)
+			h.connect(c.ID, id, l)
+		}
+		entries = entries[:0]
+		for _, c := range candidates {
+			entries = append(entries, c.ID)
+		}
+	}
+
+	if level > h.maxLevel {
+		h.entry, h.maxLevel = id, level
+	}
+}
+
+func (h *HNSWIndex) Search(query []float32, k int) []Hit {
+	if h.entry < 0 || k <= 0 {
+		return nil
+	}
+
+	ep := h.entry
+	for l := h.maxLevel; l > 0; l-- {
+		ep = h.greedy(query, ep, l)
+	}
+	hits := h.searchLayer(query, []int{ep}, max(h.options.EfSearch, k), 0)
+	if len(hits) > k {
+		hits = hits[:k]
+	}
+	return hits
+}
+
+// connect 添加 from -> to 的边，超出上限时只保留最相似的邻居
+func (h *HNSWIndex) connect(from, to, level int) {
+	limit := h.options.M
+	if level == 0 {
+		limit = 2 * h.options.M
+	}
+
+	links := append(h.neighbors[from][level], to)
+	if len(links) > limit {
+		hits := make([]Hit, len(links))
+		for i, n := range links {
+			hits[i] = Hit{ID: n, Score: dot(h.vectors[from], h.vectors[n])}
+		}
+		sortHits(hits)
+		links = links[:0]
+		for _, hit := range hits[:limit] {
+			links = append(links, hit.ID)
+		}
+	}
+	h.neighbors[from][level] = links
+}
+
+// greedy 在单层上贪心地移动到最相似的节点
+func (h *HNSWIndex) greedy(query []float32, ep, level int) int {
+	best := dot(query, h.vectors[ep])
+	for changed := true; changed; {
+		changed = false
+		for _, n := range h.neighbors[ep][level] {
+			if score := dot(query, h.vectors[n]); score > best {
+				ep, best, changed = n, score, true
+			}
+		}
+	}
+	return ep
+}
+
+// searchLayer 在单层上做 beam search，返回按相似度降序的至多 ef 个节点
+func (h *HNSWIndex) searchLayer(query []float32, entries []int, ef, level int) []Hit {
+	visited := map[int]bool{}
+	candidates := &hitHeap{max: true} // 待扩展，最相似的优先
+	results := &hitHeap{}             // 当前结果，最不相似的在堆顶
+
+	for _, ep := range entries {
+		if visited[ep] {
+			continue
+		}
+		visited[ep] = true
+		hit := Hit{ID: ep, Score: dot(query, h.vectors[ep])}
+		heap.Push(candidates, hit)
+		heap.Push(results, hit)
+	}
+
+	for candidates.Len() > 0 {
+		c := heap.Pop(candidates).(Hit)
+		if results.Len() >= ef && c.Score < results.hits[0].Score {
+			break
+		}
+		if level >= len(h.neighbors[c.ID]) {
+			continue
+		}
+		for _, n := range h.neighbors[c.ID][level] {
+			if visited[n] {
+				continue
+			}
+			visited[n] = true
+			hit := Hit{ID: n, Score: dot(query, h.vectors[n])}
+			if results.Len() < ef || hit.Score > results.hits[0].Score {
+				heap.Push(candidates, hit)
+				heap.Push(results, hit)
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+
+	hits := results.hits
+	sortHits(hits)
+	return hits
+}
+
+// hitHeap 按 Score 排序的堆，max 为 true 时为大顶堆
+type hitHeap struct {
+	hits []Hit
+	max  bool
+}
+
+func (h *hitHeap) Len() int { return len(h.hits) }
+func (h *hitHeap) Less(i, j int) bool {
+	if h.max {
+		return h.hits[i].Score > h.hits[j].Score
+	}
+	return h.hits[i].Score < h.hits[j].Score
+}
+func (h *hitHeap) Swap(i, j int) { h.hits[i], h.hits[j] = h.hits[j], h.hits[i] }
+func (h *hitHeap) Push(x any)    { h.hits = append(h.hits, x.(Hit)) }
+func (h *hitHeap) Pop() any {
+	last := h.hits[len(h.hits)-1]
+	h.hits = h.hits[:len(h.hits)-1]
+	return last
+}
+
+type hnswSnapshot struct {
+	Options   HNSWOptions
+	Vectors   [][]float32
+	Neighbors [][][]int
+	Entry     int
+	MaxLevel  int
+}
+
+func (h *HNSWIndex) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(hnswSnapshot{
+		Options:   h.options,
+		Vectors:   h.vectors,
+		Neighbors: h.neighbors,
+		Entry:     h.entry,
+		MaxLevel:  h.maxLevel,
+	})
+	return buf.Bytes(), err
+}
+
+func (h *HNSWIndex) GobDecode(data []byte) error {
+	var snapshot hnswSnapshot
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&snapshot); err != nil {
+		return err
+	}
+	*h = *NewHNSWIndex(snapshot.Options)
+	h.vectors = snapshot.Vectors
+	h.neighbors = snapshot.Neighbors
+	h.entry = snapshot.Entry
+	h.maxLevel = snapshot.MaxLevel
+	return nil
+}
@@ -0,0 +1,242 @@
+package vectorstore
+
+import (
+	"encoding/gob"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Document 存储的文档片段
+type Document struct {
+	ID       string            `json:"id"`
+	Text     string            `json:"text"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Vector   []float32         `json:"vector"`
+}
+
+// Result 检索结果
+type Result struct {
+	Document
+	Score float64 `json:"score"` // 余弦相似度
+}
+
+// Hit 索引命中，ID 为文档在存储中的序号
+type Hit struct {
+	ID    int
+	Score float64
+}
+
+// Index 向量索引接口，传入的向量均已归一化，Score 为内积
+type Index interface {
+	// Add 添加向量，id 连续递增
+	Add(id int, vector []float32)
+	// Search 返回与 query 最相似的至多 k 个结果，按 Score 降序
+	Search(query []float32, k int) []Hit
+}
+
+func init() {
+	gob.Register(&FlatIndex{})
+	gob.Register(&HNSWIndex{})
+}
+
+// Store 内存向量存储，删除采用标记方式，Save 时一并持久化索引
+type Store struct {
+	docs    []Document
+	deleted []bool
+	byID    map[string]int
+	dims    int
+	index   Index
+	mu      sync.RWMutex
+}
+
+// NewStore 创建向量存储，index 为空时使用暴力检索
+func NewStore(index Index) *Store {
+	if index == nil {
+		index = NewFlatIndex()
+	}
+	return &Store{
+		byID:  make(map[string]int),
+		index: index,
+	}
+}
+
+// Add 添加文档，ID 已存在时覆盖旧文档
+func (s *Store) Add(docs ...Document) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, doc := range docs {
+		if doc.ID == "" {
+			return fmt.Errorf("document id is required")
+		}
+		if len(doc.Vector) == 0 {
+			return fmt.Errorf("document %s has no vector", doc.ID)
+		}
+		if s.dims == 0 {
+			s.dims = len(doc.Vector)
+		}
+		if len(doc.Vector) != s.dims {
+			return fmt.Errorf("document %s has %d dimensions, expected %d", doc.ID, len(doc.Vector), s.dims)
+		}
+
+		if old, ok := s.byID[doc.ID]; ok {
+			s.deleted[old] = true
+		}
+		id := len(s.docs)
+		s.docs = append(s.docs, doc)
+		s.deleted = append(s.deleted, false)
+		s.byID[doc.ID] = id
+		s.index.Add(id, normalize(doc.Vector))
+	}
+	return nil
+}
+
+// Delete 删除文档
+func (s *Store) Delete(ids ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range ids {
+		if i, ok := s.byID[id]; ok {
+			s.deleted[i] = true
+			delete(s.byID, id)
+		}
+	}
+}
+
+// Get 按 ID 获取文档
+func (s *Store) Get(id string) (Document, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i, ok := s.byID[id]
+	if !ok {
+		return Document{}, false
+	}
+	return s.docs[i], true
+}
+
+// Len 返回文档数
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.byID)
+}
+
+// Search 检索与 query 最相似的 k 个文档
+func (s *Store) Search(query []float32, k int) []Result {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if k <= 0 || len(s.byID) == 0 || len(query) != s.dims {
+		return nil
+	}
+
+	// 多取已删除的数量，过滤后仍能凑够 k 个
+	hits := s.index.Search(normalize(query), k+len(s.docs)-len(s.byID))
+	results := make([]Result, 0, k)
+	for _, hit := range hits {
+		if s.deleted[hit.ID] {
+			continue
+		}
+		results = append(results, Result{Document: s.docs[hit.ID], Score: hit.Score})
+		if len(results) == k {
+			break
+		}
+	}
+	return results
+}
+
+type storeSnapshot struct {
+	Docs    []Document
+	Deleted []bool
+	Dims    int
+	Index   Index
+}
+
+// Save 将文档和索引持久化到文件
+func (s *Store) Save(path string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	snapshot := storeSnapshot{Docs: s.docs, Deleted: s.deleted, Dims: s.dims, Index: s.index}
+	if err = gob.NewEncoder(tmp).Encode(&snapshot); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Load 从文件加载向量存储
+func Load(path string) (*Store, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var snapshot storeSnapshot
+	if err = gob.NewDecoder(f).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("load vector store %s: %v", path, err)
+	}
+
+	s := &Store{
+		docs:    snapshot.Docs,
+		deleted: snapshot.Deleted,
+		byID:    make(map[string]int, len(snapshot.Docs)),
+		dims:    snapshot.Dims,
+		index:   snapshot.Index,
+	}
+	for i, doc := range s.docs {
+		if !s.deleted[i] {
+			s.byID[doc.ID] = i
+		}
+	}
+	return s, nil
+}
+
+func normalize(v []float32) []float32 {
+	var norm float64
+	for _, x := range v {
+		norm += float64(x) * float64(x)
+	}
+	result := make([]float32, len(v))
+	if norm == 0 {
+		return result
+	}
+	norm = math.Sqrt(norm)
+	for i, x := range v {
+		result[i] = float32(float64(x) / norm)
+	}
+	return result
+}
+
+func dot(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
+
+func sortHits(hits []Hit) {
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+}
@@ -0,0 +1,116 @@
+package vectorstore
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"path/filepath"
+	"testing"
+)
+
+func randomVectors(n, dims int, seed uint64) [][]float32 {
+	rng := rand.New(rand.NewPCG(seed, seed))
+	vectors := make([][]float32, n)
+	for i := range vectors {
+		vectors[i] = make([]float32, dims)
+		for j := range vectors[i] {
+			vectors[i][j] = float32(rng.NormFloat64())
+		}
+	}
+	return vectors
+}
+
+func newTestStore(t *testing.T, index Index, vectors [][]float32) *Store {
+	t.Helper()
+	s := NewStore(index)
+	for i, v := range vectors {
+		if err := s.Add(Document{ID: fmt.Sprint(i), Text: fmt.Sprint("doc ", i), Vector: v}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func TestStore_Search(t *testing.T) {
+	s := newTestStore(t, nil, [][]float32{{1, 0}, {0, 1}, {1, 1}})
+
+	results := s.Search([]float32{2, 0}, 2)
+	if len(results) != 2 || results[0].ID != "0" || results[1].ID != "2" {
+		t.Errorf("Expected [0 2], got %+v", results)
+	}
+	if results[0].Score < 0.999 {
+		t.Errorf("Expected cosine similarity 1, got %f", results[0].Score)
+	}
+
+	s.Delete("0")
+	if results = s.Search([]float32{1, 0}, 2); len(results) != 2 || results[0].ID != "2" {
+		t.Errorf("Expected deleted document to be skipped, got %+v", results)
+	}
+
+	// 覆盖已有文档
+	if err := s.Add(Document{ID: "1", Vector: []float32{1, 0}}); err != nil {
+		t.Fatal(err)
+	}
+	if s.Len() != 2 {
+		t.Errorf("Expected 2 documents, got %d", s.Len())
+	}
+	if results = s.Search([]float32{1, 0}, 1); results[0].ID != "1" {
+		t.Errorf("Expected updated document, got %+v", results)
+	}
+
+	if err := s.Add(Document{ID: "x", Vector: []float32{1, 2, 3}}); err == nil {
+		t.Error("Expected dimension mismatch error")
+	}
+}
+
+func TestHNSWIndex_Recall(t *testing.T) {
+	vectors := randomVectors(1000, 16, 1)
+	flat := newTestStore(t, NewFlatIndex(), vectors)
+	hnsw := newTestStore(t, NewHNSWIndex(HNSWOptions{Seed: 1}), vectors)
+
+	found, total := 0, 0
+	for _, q := range randomVectors(50, 16, 2) {
+		expected := map[string]bool{}
+		for _, r := range flat.Search(q, 10) {
+			expected[r.ID] = true
+		}
+		for _, r := range hnsw.Search(q, 10) {
+			if expected[r.ID] {
+				found++
+			}
+		}
+		total += 10
+	}
+	if recall := float64(found) / float64(total); recall < 0.9 {
+		t.Errorf("Expected recall >= 0.9, got %.2f", recall)
+	}
+}
+
+func TestStore_SaveLoad(t *testing.T) {
+	vectors := randomVectors(200, 8, 3)
+	for name, index := range map[string]Index{"Flat": NewFlatIndex(), "HNSW": NewHNSWIndex(HNSWOptions{})} {
+		t.Run(name, func(t *testing.T) {
+			s := newTestStore(t, index, vectors)
+			s.Delete("5")
+
+			path := filepath.Join(t.TempDir(), "store.gob")
+			if err := s.Save(path); err != nil {
+				t.Fatal(err)
+			}
+			loaded, err := Load(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if loaded.Len() != s.Len() {
+				t.Errorf("Expected %d documents, got %d", s.Len(), loaded.Len())
+			}
+			if _, ok := loaded.Get("5"); ok {
+				t.Error("Expected deleted document to stay deleted")
+			}
+			q := vectors[7]
+			if a, b := s.Search(q, 5), loaded.Search(q, 5); fmt.Sprint(a) != fmt.Sprint(b) {
+				t.Errorf("Expected identical results after reload, got %v and %v", a, b)
+			}
+		})
+	}
+}